@@ -0,0 +1,71 @@
+package hive
+
+// LowBatteryFunc is called when the battery level of a device drops below the
+// threshold given to OnLowBattery.
+type LowBatteryFunc func(device *Device, level int)
+
+type batteryAlert struct {
+	threshold int
+	fn        LowBatteryFunc
+}
+
+// crossed returns true if the battery level of the device dropped below the
+// threshold. A device seen for the first time with a low battery counts as
+// having crossed it.
+func (a *batteryAlert) crossed(previous *jsonEntity, current *jsonEntity) bool {
+	level := batteryLevel(current)
+	if level < 0 || level >= a.threshold {
+		return false
+	}
+	if previous == nil {
+		return true
+	}
+	previousLevel := batteryLevel(previous)
+	return previousLevel < 0 || previousLevel >= a.threshold
+}
+
+// HasBattery returns true if this device runs on batteries and reports their
+// level.
+func (d *Device) HasBattery() bool {
+	return d.BatteryLevel() >= 0
+}
+
+// BatteryLevel returns the battery level of this device as a percentage between
+// 0 and 100, or -1 if the device doesn't report it.
+func (d *Device) BatteryLevel() int {
+	return batteryLevel(d.snapshot())
+}
+
+func batteryLevel(e *jsonEntity) int {
+	if e.Props.Battery == nil {
+		return -1
+	}
+	return clamp(*e.Props.Battery, 0, 100)
+}
+
+// LowBatteryDevices returns the devices with a battery level below the given
+// percentage, ordered the same way as in the Hive app.
+func (c *Client) LowBatteryDevices(threshold int) []*Device {
+	var devices []*Device
+	for _, device := range c.Devices() {
+		if level := device.BatteryLevel(); level >= 0 && level < threshold {
+			devices = append(devices, device)
+		}
+	}
+	sortDevices(devices)
+	return devices
+}
+
+// OnLowBattery registers a function that's called whenever the battery level of
+// a device drops below the given percentage while loading devices, including
+// when a device with a low battery is loaded for the first time. It replaces
+// any previously registered function; passing nil removes it.
+func (c *Client) OnLowBattery(threshold int, fn LowBatteryFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fn == nil {
+		c.lowBattery = nil
+		return
+	}
+	c.lowBattery = &batteryAlert{threshold, fn}
+}
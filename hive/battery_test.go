@@ -0,0 +1,46 @@
+package hive
+
+import "testing"
+
+func TestLowBattery(t *testing.T) {
+	mock := &mockEndpoint{}
+	client := &Client{client: mock}
+
+	alerts := make(map[string]int)
+	client.OnLowBattery(20, func(device *Device, level int) {
+		alerts[device.ID()] = level
+	})
+
+	mock.result = `[
+		{"id": "low", "type": "motionsensor", "props": {"battery": 10}},
+		{"id": "dropping", "type": "motionsensor", "props": {"battery": 50}},
+		{"id": "mains", "type": "warmwhitelight"}
+	]`
+	if err := client.RefreshDevices(); err != nil {
+		t.Fatalf("client.RefreshDevices returned error: %v", err)
+	}
+	if len(alerts) != 1 || alerts["low"] != 10 {
+		t.Errorf("low battery alerts after first refresh are %v, want only low", alerts)
+	}
+
+	alerts = make(map[string]int)
+	mock.result = `[
+		{"id": "low", "type": "motionsensor", "props": {"battery": 9}},
+		{"id": "dropping", "type": "motionsensor", "props": {"battery": 15}},
+		{"id": "mains", "type": "warmwhitelight"}
+	]`
+	if err := client.RefreshDevices(); err != nil {
+		t.Fatalf("client.RefreshDevices returned error: %v", err)
+	}
+	if len(alerts) != 1 || alerts["dropping"] != 15 {
+		t.Errorf("low battery alerts after second refresh are %v, want only dropping", alerts)
+	}
+
+	devices := client.LowBatteryDevices(20)
+	if len(devices) != 2 || devices[0].ID() != "dropping" || devices[1].ID() != "low" {
+		t.Errorf("client.LowBatteryDevices returned %v, want dropping and low", devices)
+	}
+	if client.Device("mains").HasBattery() {
+		t.Error("HasBattery returned true for device without battery level")
+	}
+}
@@ -0,0 +1,223 @@
+package hive
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returned (wrapped in an UnsupportedError) when applying a change that uses a
+// capability the device doesn't have.
+var ErrNotSupported = errors.New("not supported by device")
+
+// Capabilities describes the changes a device supports.
+type Capabilities struct {
+	// Known is false if the type of the device is unknown, in which case its
+	// capabilities can't be checked, so all the other fields are empty and any
+	// change is allowed to be sent.
+	Known bool
+
+	// OnOff is true if the device can be turned on and off.
+	OnOff bool
+
+	// Dimmable is true if the brightness of the device can be set.
+	Dimmable bool
+
+	// Color is true if the color of the device can be set.
+	Color bool
+
+	// ColorTemperature is true if the color temperature of the device can be
+	// set, between MinColorTemperature and MaxColorTemperature kelvins.
+	ColorTemperature    bool
+	MinColorTemperature int
+	MaxColorTemperature int
+
+	// Heating is true if the device controls heating, so it can be boosted and
+	// its target temperature can be set, between MinTargetTemperature and
+	// MaxTargetTemperature degrees Celsius.
+	Heating              bool
+	MinTargetTemperature float64
+	MaxTargetTemperature float64
+
+	// HotWater is true if the device controls hot water, so it can be boosted.
+	HotWater bool
+
+	// Schedule is true if the device has a weekly on and off schedule that can
+	// be set with Change.Schedule.
+	Schedule bool
+
+	// Modes contains the modes the device can be switched to.
+	Modes []Mode
+}
+
+var (
+	lightModes   = []Mode{ModeSchedule, ModeManual}
+	heatingModes = []Mode{ModeSchedule, ModeManual, ModeOff, ModeBoost}
+
+	// deviceCapabilities contains the capabilities of every known device type.
+	deviceCapabilities = map[string]Capabilities{
+		typeWarmWhiteLight: {
+			OnOff:    true,
+			Dimmable: true,
+			Schedule: true,
+			Modes:    lightModes,
+		},
+		typeTuneableLight: {
+			OnOff:               true,
+			Dimmable:            true,
+			ColorTemperature:    true,
+			MinColorTemperature: colorWarm,
+			MaxColorTemperature: colorCold,
+			Schedule:            true,
+			Modes:               lightModes,
+		},
+		typeColourLight: {
+			OnOff:               true,
+			Dimmable:            true,
+			Color:               true,
+			ColorTemperature:    true,
+			MinColorTemperature: colorWarm,
+			MaxColorTemperature: colorCold,
+			Schedule:            true,
+			Modes:               lightModes,
+		},
+		typePlug: {
+			OnOff:    true,
+			Schedule: true,
+			Modes:    lightModes,
+		},
+		typeHeating: {
+			Heating:              true,
+			MinTargetTemperature: heatingMinTemperature,
+			MaxTargetTemperature: heatingMaxTemperature,
+			Modes:                heatingModes,
+		},
+		typeTRV: {
+			Heating:              true,
+			MinTargetTemperature: heatingMinTemperature,
+			MaxTargetTemperature: heatingMaxTemperature,
+			Modes:                heatingModes,
+		},
+		typeHotWater:      {HotWater: true, Modes: heatingModes},
+		typeMotionSensor:  {},
+		typeContactSensor: {},
+		typeHub:           {},
+	}
+)
+
+// UnsupportedError is returned when applying a change that uses a capability
+// the device doesn't have.
+type UnsupportedError struct {
+	// DeviceID is the ID of the device the change was applied to.
+	DeviceID string
+
+	// DeviceType is the type of the device the change was applied to.
+	DeviceType string
+
+	// Capability describes what the device doesn't support, such as "color".
+	Capability string
+
+	// mode is set if the device doesn't support switching to a mode.
+	mode bool
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s (%s) doesn't support %s", e.DeviceID, e.DeviceType, e.Capability)
+}
+
+// Is makes the error match ErrNotSupported, as well as ErrModeNotSupported if
+// it's caused by an unsupported mode.
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrNotSupported || (e.mode && target == ErrModeNotSupported)
+}
+
+// Capabilities returns the changes this device supports. For devices of unknown
+// types, Known is false.
+func (d *Device) Capabilities() Capabilities {
+	return capabilitiesOf(d.Type())
+}
+
+func capabilitiesOf(deviceType string) Capabilities {
+	capabilities, ok := deviceCapabilities[deviceType]
+	capabilities.Known = ok
+	// The modes are shared by every device of the same kind, so they're copied
+	// to keep callers from changing them.
+	capabilities.Modes = append([]Mode(nil), capabilities.Modes...)
+	return capabilities
+}
+
+// SupportsMode returns true if this device can be switched to the given mode.
+// Like Do, it assumes devices of unknown types support every mode.
+func (d *Device) SupportsMode(mode Mode) bool {
+	return d.Capabilities().supportsMode(mode)
+}
+
+func (c Capabilities) supportsMode(mode Mode) bool {
+	if !c.Known {
+		return true
+	}
+	for _, m := range c.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSupported returns an error if the device doesn't have the capabilities
+// needed to apply the state. Devices of unknown types are assumed to support
+// everything.
+func (d *Device) checkSupported(state *jsonState) error {
+	e := d.snapshot()
+	capabilities := capabilitiesOf(e.Type)
+	if !capabilities.Known {
+		return nil
+	}
+	unsupported := func(capability string) *UnsupportedError {
+		return &UnsupportedError{DeviceID: e.ID, DeviceType: e.Type, Capability: capability}
+	}
+
+	if state.Status != nil && !capabilities.OnOff {
+		return unsupported("turning on and off")
+	}
+	if state.Brightness != nil && !capabilities.Dimmable {
+		return unsupported("brightness")
+	}
+	if (state.Hue != nil || state.Saturation != nil || state.Value != nil) && !capabilities.Color {
+		return unsupported("color")
+	}
+	if state.ColourMode != nil && *state.ColourMode == colourModeCOLOUR && !capabilities.Color {
+		return unsupported("color")
+	}
+	if state.ColourTemperature != nil {
+		if !capabilities.ColorTemperature {
+			return unsupported("color temperature")
+		}
+		temperature := *state.ColourTemperature
+		if temperature < capabilities.MinColorTemperature || temperature > capabilities.MaxColorTemperature {
+			return unsupported(fmt.Sprintf("color temperature %dK, only %dK to %dK",
+				temperature, capabilities.MinColorTemperature, capabilities.MaxColorTemperature))
+		}
+	}
+	if state.Target != nil {
+		if !capabilities.Heating {
+			return unsupported("target temperature")
+		}
+		target := *state.Target
+		if target < capabilities.MinTargetTemperature || target > capabilities.MaxTargetTemperature {
+			return unsupported(fmt.Sprintf("target temperature %v°C, only %v°C to %v°C",
+				target, capabilities.MinTargetTemperature, capabilities.MaxTargetTemperature))
+		}
+	}
+	if state.Boost != nil && !capabilities.Heating && !capabilities.HotWater {
+		return unsupported("boost")
+	}
+	if state.Schedule != nil && !capabilities.Schedule {
+		return unsupported("on and off schedules")
+	}
+	if state.Mode != nil && !capabilities.supportsMode(Mode(*state.Mode)) {
+		err := unsupported("mode " + *state.Mode)
+		err.mode = true
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package hive
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckSupported(t *testing.T) {
+	outOfRange := 40.0
+	tests := []struct {
+		deviceType string
+		change     *Change
+		supported  bool
+	}{
+		{typeWarmWhiteLight, NewChange().TurnOn().Brightness(50), true},
+		{typeWarmWhiteLight, NewChange().Color(ColorRed), false},
+		{typeWarmWhiteLight, NewChange().ColorTemperature(3000), false},
+		{typeTuneableLight, NewChange().ColorTemperature(3000), true},
+		{typeColourLight, NewChange().Color(ColorRed), true},
+		{typeMotionSensor, NewChange().Brightness(50), false},
+		{typeMotionSensor, NewChange().Name("Hallway"), true},
+		{typePlug, NewChange().TurnOff(), true},
+		{typePlug, NewChange().Brightness(50), false},
+		{typeHeating, NewChange().TargetTemperature(20), true},
+		{typeTRV, NewChange().Boost(time.Hour, 25), true},
+		{typeTRV, &Change{state: jsonState{Target: &outOfRange}}, false},
+		{typeHotWater, NewChange().TargetTemperature(20), false},
+		{typeHotWater, NewChange().Mode(ModeOff), true},
+		{"unknowndevice", NewChange().Color(ColorRed), true},
+	}
+	for _, test := range tests {
+		mock := &mockEndpoint{}
+		device := &Device{client: &Client{client: mock}, entity: &jsonEntity{ID: "1", Type: test.deviceType}}
+		err := device.Do(test.change)
+		if test.supported && err != nil {
+			t.Errorf("Do on %s returned error %v, want nil", test.deviceType, err)
+		}
+		if !test.supported && (!errors.Is(err, ErrNotSupported) || mock.payload != "") {
+			t.Errorf("Do on %s returned %v and sent %q, want %v without a request", test.deviceType, err, mock.payload, ErrNotSupported)
+		}
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	device := &Device{entity: &jsonEntity{Type: typeColourLight}}
+	capabilities := device.Capabilities()
+	if !capabilities.Known || !capabilities.Color || capabilities.MinColorTemperature != colorWarm || capabilities.Heating {
+		t.Errorf("Capabilities returned %+v for color light", capabilities)
+	}
+	if device.SupportsMode(ModeOff) || !device.SupportsMode(ModeSchedule) {
+		t.Error("SupportsMode returned wrong values for color light")
+	}
+	capabilities.Modes[0] = ModeOff
+	if !device.SupportsMode(ModeSchedule) {
+		t.Error("changing the returned modes changed the capabilities of every light")
+	}
+
+	unknown := &Device{entity: &jsonEntity{Type: "unknowndevice"}}
+	if unknown.Capabilities().Known || !unknown.SupportsMode(ModeOff) {
+		t.Error("unknown device type not reported as unknown and supporting every mode")
+	}
+}
@@ -1,6 +1,10 @@
 package hive
 
-import "errors"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Change represents a single update to the device's current state. For example,
 // for a light bulb, a change may include both turning it on and setting the
@@ -9,21 +13,126 @@ import "errors"
 //
 // Change methods are built to be chained, so an example use would be:
 //     someDevice.Do(hive.NewChange().TurnOn().Brightness(50))
+//
+// Methods documented to panic on invalid values record a ValidationError
+// instead if the change was created with NewCheckedChange.
 type Change struct {
 	state jsonState
+
+	// cancelBoost is set if the change cancels a boost, which requires knowing
+	// the device state before the boost, so it's resolved when the change is
+	// applied.
+	cancelBoost bool
+
+	// checked is set if invalid values are recorded in errs instead of causing
+	// a panic.
+	checked bool
+	errs    []*ValidationError
+}
+
+// ValidationError is the error caused by passing an invalid value to a method
+// of Change.
+type ValidationError struct {
+	// Field is the name of the value that's invalid, such as "brightness".
+	Field string
+
+	// Value is the invalid value.
+	Value interface{}
+
+	// Reason describes why the value is invalid.
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Value == nil {
+		return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("invalid %s %v: %s", e.Field, e.Value, e.Reason)
 }
 
-// NewChange simply returns an empty Change object.
+// ValidationErrors contains all the validation errors of a change, in the order
+// they were recorded. It's returned by Change.Err.
+type ValidationErrors struct {
+	Errors []*ValidationError
+}
+
+func (e *ValidationErrors) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the validation errors, so errors.As can be used to obtain the
+// first one.
+func (e *ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// NewChange simply returns an empty Change object. Passing invalid values to
+// its methods results in a panic.
 func NewChange() *Change {
 	return &Change{}
 }
 
+// NewCheckedChange returns an empty Change object that records invalid values
+// passed to its methods instead of panicking. Methods called with an invalid
+// value leave the change as it was, and the errors are returned by Err and by
+// the Do method of a device.
+func NewCheckedChange() *Change {
+	return &Change{checked: true}
+}
+
+// Err returns the validation errors recorded by a change created with
+// NewCheckedChange as *ValidationErrors, or nil if there are none.
+func (c *Change) Err() error {
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return &ValidationErrors{append([]*ValidationError(nil), c.errs...)}
+}
+
+// invalid records a validation error, or panics with it if the change isn't
+// checked. It always returns false, so it can be used as the result of checks.
+func (c *Change) invalid(field string, value interface{}, reason string) bool {
+	err := &ValidationError{field, value, reason}
+	if !c.checked {
+		panic(err)
+	}
+	c.errs = append(c.errs, err)
+	return false
+}
+
+// inRange checks that the value is between min and max (inclusive), recording a
+// validation error otherwise.
+func (c *Change) inRange(field string, value int, min int, max int) bool {
+	if value < min || value > max {
+		return c.invalid(field, value, fmt.Sprintf("must be between %d and %d", min, max))
+	}
+	return true
+}
+
+// temperatureInRange checks that the target temperature of heating is valid,
+// recording a validation error otherwise.
+func (c *Change) temperatureInRange(temperature float64) bool {
+	if temperature < heatingMinTemperature || temperature > heatingMaxTemperature {
+		reason := fmt.Sprintf("must be between %v and %v", heatingMinTemperature, heatingMaxTemperature)
+		return c.invalid("target temperature", temperature, reason)
+	}
+	return true
+}
+
 // Brightness makes this change set the brightness to the given value. Valid
 // values are numbers between 0 and 100 (inclusive). An invalid value will
 // result in a panic.
 func (c *Change) Brightness(brightness int) *Change {
-	if brightness < 0 || brightness > 100 {
-		panic(errors.New("brightness must be between 0 and 100"))
+	if !c.inRange("brightness", brightness, 0, 100) {
+		return c
 	}
 	c.state.Brightness = &brightness
 	c.resetHSV()
@@ -40,14 +149,11 @@ func (c *Change) Name(name string) *Change {
 // the lightbulb mode to *color* if set to *color temperature*. This method will
 // panic if either of hue, saturation or value are set to an invalid value.
 func (c *Change) Color(hsv HSV) *Change {
-	if hsv.Hue < 0 || hsv.Hue > 359 {
-		panic(errors.New("hue must be between 0 and 359"))
-	}
-	if hsv.Saturation < 0 || hsv.Saturation > 99 {
-		panic(errors.New("saturation must be between 0 and 99"))
-	}
-	if hsv.Value < 0 || hsv.Value > 100 {
-		panic(errors.New("value must be between 0 and 100"))
+	valid := c.inRange("hue", hsv.Hue, 0, 359)
+	valid = c.inRange("saturation", hsv.Saturation, 0, 99) && valid
+	valid = c.inRange("value", hsv.Value, 0, 100) && valid
+	if !valid {
+		return c
 	}
 	c.state.ColourMode = &colourModeCOLOUR
 	c.state.Hue = &hsv.Hue
@@ -63,8 +169,8 @@ func (c *Change) Color(hsv HSV) *Change {
 // temperature* if set to *color*. Valid values are numbers between 2700 and
 // 6535 (inclusive). An invalid value will result in a panic.
 func (c *Change) ColorTemperature(temperature int) *Change {
-	if temperature < colorWarm || temperature > colorCold {
-		panic(errors.New("temperature must be between 0 and 1"))
+	if !c.inRange("color temperature", temperature, colorWarm, colorCold) {
+		return c
 	}
 
 	c.state.ColourMode = &colourModeWHITE
@@ -78,25 +184,158 @@ func (c *Change) ColorTemperature(temperature int) *Change {
 // temperature* if set to *color*. Valid values are numbers between 0 and 100
 // (inclusive). An invalid value will result in a panic.
 func (c *Change) ColorTemperaturePercent(percent int) *Change {
-	if percent < 0 || percent > 100 {
-		panic(errors.New("temperature must be between 0 and 100"))
+	if !c.inRange("color temperature percentage", percent, 0, 100) {
+		return c
 	}
 
 	return c.ColorTemperature(percentToTemperature(percent))
 }
 
-// TurnOn makes this change turn the light on.
+// Schedule makes this change replace the weekly schedule of the device. Days
+// missing from the schedule are sent without entries. An invalid schedule (see
+// Schedule.Validate) will result in a panic.
+func (c *Change) Schedule(s Schedule) *Change {
+	if err := s.Validate(); err != nil {
+		c.invalid("schedule", nil, err.Error())
+		return c
+	}
+	c.state.Schedule = s.toJSON()
+	return c
+}
+
+// TurnOn makes this change turn the light or plug on.
 func (c *Change) TurnOn() *Change {
 	c.state.Status = &statusON
 	return c
 }
 
-// TurnOff makes this change turn the light off.
+// TurnOff makes this change turn the light or plug off.
 func (c *Change) TurnOff() *Change {
 	c.state.Status = &statusOFF
 	return c
 }
 
+// TargetTemperature makes this change set the target temperature of a heating
+// device, in degrees Celsius. Valid values are numbers between 5 and 32
+// (inclusive). An invalid value will result in a panic.
+func (c *Change) TargetTemperature(temperature float64) *Change {
+	if !c.temperatureInRange(temperature) {
+		return c
+	}
+	c.state.Target = &temperature
+	return c
+}
+
+// Mode makes this change switch the device to the given mode. Valid values are
+// ModeSchedule, ModeManual and ModeOff; use Boost or HotWaterBoost to start a
+// boost. An invalid value will result in a panic. Devices that don't support the
+// mode (for example, lights can't be switched to ModeOff) will return an error
+// when the change is applied.
+func (c *Change) Mode(mode Mode) *Change {
+	if !isSettableMode(mode) {
+		c.invalid("mode", mode, "must be SCHEDULE, MANUAL or OFF")
+		return c
+	}
+	c.setMode(mode)
+	return c
+}
+
+// HeatingMode makes this change set the mode of a heating device. It's the same
+// as Mode.
+func (c *Change) HeatingMode(mode Mode) *Change {
+	return c.Mode(mode)
+}
+
+// HotWaterMode makes this change set the mode of a hot water device, where
+// ModeManual means the water is always kept hot. It's the same as Mode.
+func (c *Change) HotWaterMode(mode Mode) *Change {
+	return c.Mode(mode)
+}
+
+// Boost makes this change heat to the given temperature (in degrees Celsius)
+// for the given duration, after which the heating device returns to its
+// previous mode. The duration is rounded to whole minutes and must be between
+// 1 minute and 6 hours, while the temperature must be between 5 and 32
+// (inclusive). An invalid value will result in a panic.
+func (c *Change) Boost(duration time.Duration, temperature float64) *Change {
+	minutes, valid := c.boostMinutes(duration)
+	valid = c.temperatureInRange(temperature) && valid
+	if !valid {
+		return c
+	}
+	c.state.Target = &temperature
+	c.setMode(ModeBoost)
+	c.state.Boost = &minutes
+	return c
+}
+
+// HotWaterBoost makes this change heat water for the given duration, after
+// which the hot water device returns to its previous mode. The duration is
+// rounded to whole minutes and must be between 1 minute and 6 hours. An invalid
+// value will result in a panic.
+func (c *Change) HotWaterBoost(duration time.Duration) *Change {
+	minutes, valid := c.boostMinutes(duration)
+	if !valid {
+		return c
+	}
+	c.setMode(ModeBoost)
+	c.state.Boost = &minutes
+	return c
+}
+
+// CancelBoost makes this change stop a running boost and return the device to
+// the mode (and for heating, the target temperature) it had before the boost.
+func (c *Change) CancelBoost() *Change {
+	c.state.Mode = nil
+	c.state.Boost = nil
+	c.state.Target = nil
+	c.cancelBoost = true
+	return c
+}
+
+// isSettableMode returns true if the mode can be set directly, rather than by
+// starting a boost.
+func isSettableMode(mode Mode) bool {
+	return mode == ModeSchedule || mode == ModeManual || mode == ModeOff
+}
+
+func (c *Change) setMode(mode Mode) {
+	m := string(mode)
+	c.state.Mode = &m
+	if mode != ModeBoost {
+		c.state.Boost = nil
+	}
+	c.cancelBoost = false
+}
+
+// boostMinutes converts the duration of a boost to minutes, recording a
+// validation error if it's out of range.
+func (c *Change) boostMinutes(duration time.Duration) (int, bool) {
+	minutes := int(duration.Round(time.Minute) / time.Minute)
+	if minutes < 1 || duration > boostMaxDuration {
+		return 0, c.invalid("boost duration", duration, "must be between 1 minute and 6 hours")
+	}
+	return minutes, true
+}
+
+// stateFor returns the state to send to the given device to apply this change.
+func (c *Change) stateFor(entity *jsonEntity) *jsonState {
+	state := c.state
+	if c.cancelBoost {
+		mode := string(ModeSchedule)
+		if prev := entity.Props.Previous; prev != nil {
+			if prev.Mode != nil && *prev.Mode != "" && *prev.Mode != string(ModeBoost) {
+				mode = *prev.Mode
+			}
+			if prev.Target != nil && state.Target == nil {
+				state.Target = prev.Target
+			}
+		}
+		state.Mode = &mode
+	}
+	return &state
+}
+
 func (c *Change) resetHSV() {
 	c.state.Hue = nil
 	c.state.Saturation = nil
@@ -1,6 +1,10 @@
 package hive
 
-import "testing"
+import (
+	"errors"
+	"testing"
+	"time"
+)
 
 func TestChange(t *testing.T) {
 	change := NewChange().Brightness(55).TurnOff().TurnOn().Name("test")
@@ -14,3 +18,82 @@ func TestChange(t *testing.T) {
 		t.Errorf("Change has name set to %s, expected test", *change.state.Name)
 	}
 }
+
+func TestChangeBoost(t *testing.T) {
+	change := NewChange().HeatingMode(ModeManual).Boost(90*time.Minute, 21.5)
+	if *change.state.Mode != string(ModeBoost) {
+		t.Errorf("Change has mode set to %q, expected %q", *change.state.Mode, ModeBoost)
+	}
+	if *change.state.Boost != 90 {
+		t.Errorf("Change has boost set to %d, expected 90", *change.state.Boost)
+	}
+	if *change.state.Target != 21.5 {
+		t.Errorf("Change has target set to %v, expected 21.5", *change.state.Target)
+	}
+
+	change.CancelBoost()
+	state := change.stateFor(&jsonEntity{})
+	if *state.Mode != string(ModeSchedule) || state.Boost != nil {
+		t.Errorf("Change cancelling boost has mode %q and boost %v, expected %q and nil", *state.Mode, state.Boost, ModeSchedule)
+	}
+}
+
+func TestChangeBoostInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Boost did not panic for a duration longer than 6 hours")
+		}
+	}()
+	NewChange().Boost(7*time.Hour, 20)
+}
+
+func TestCheckedChange(t *testing.T) {
+	change := NewCheckedChange().Brightness(150).TurnOn().Color(HSV{400, 50, 200}).ColorTemperature(2000)
+	if change.state.Brightness != nil || change.state.Hue != nil || change.state.ColourTemperature != nil {
+		t.Error("Change applied invalid values")
+	}
+	if *change.state.Status != statusON {
+		t.Errorf("Change has status set to %q, expected %q", *change.state.Status, statusON)
+	}
+
+	var errs *ValidationErrors
+	if !errors.As(change.Err(), &errs) {
+		t.Fatalf("Change.Err returned %v, expected ValidationErrors", change.Err())
+	}
+	fields := []string{"brightness", "hue", "value", "color temperature"}
+	if len(errs.Errors) != len(fields) {
+		t.Fatalf("Change.Err returned %d errors, expected %d: %v", len(errs.Errors), len(fields), errs)
+	}
+	for i, field := range fields {
+		if errs.Errors[i].Field != field {
+			t.Errorf("Change error %d is for %q, expected %q", i, errs.Errors[i].Field, field)
+		}
+	}
+	var first *ValidationError
+	if !errors.As(change.Err(), &first) || first.Field != "brightness" {
+		t.Errorf("errors.As found %v, expected the brightness error", first)
+	}
+
+	mock := &mockEndpoint{}
+	device := &Device{client: &Client{client: mock}, entity: &jsonEntity{}}
+	if err := device.Do(change); !errors.As(err, &errs) {
+		t.Errorf("Do returned %v, expected the validation errors", err)
+	}
+	if mock.payload != "" {
+		t.Error("Do sent a request for a change with validation errors")
+	}
+
+	if err := NewCheckedChange().TurnOn().Err(); err != nil {
+		t.Errorf("Change.Err returned %v for a valid change, expected nil", err)
+	}
+}
+
+func TestChangePanics(t *testing.T) {
+	defer func() {
+		err, ok := recover().(*ValidationError)
+		if !ok || err.Field != "color temperature" {
+			t.Errorf("ColorTemperature panicked with %v, expected a ValidationError", err)
+		}
+	}()
+	NewChange().ColorTemperature(2000)
+}
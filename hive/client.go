@@ -5,14 +5,20 @@ Hive Home smart devices.
 package hive
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
 	"path"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
 	refreshDevicesTarget = "products?after="
+	hubsTarget           = "devices"
 	deviceTarget         = "nodes"
 )
 
@@ -22,40 +28,127 @@ var (
 
 	// Returned when the login fails to the server not returning an endpoint.
 	ErrNoEndpoint = errors.New("no endpoint URL returned from server")
+
+	// Returned when logging in again is needed, but no credentials are available.
+	ErrNoCredentials = errors.New("no credentials available")
+
+	// Returned when applying a change that switches a device to a mode it
+	// doesn't support.
+	ErrModeNotSupported = errors.New("mode not supported")
+
+	// Returned when refreshing a device the client doesn't know about.
+	ErrDeviceNotFound = errors.New("device not found")
 )
 
 type endpoint interface {
-	PostJSON(url string, jsonStr []byte, token string) ([]byte, error)
-	Get(url string, token string) ([]byte, error)
+	PostJSON(ctx context.Context, url string, jsonStr []byte, token string) ([]byte, error)
+	Get(ctx context.Context, url string, token string) ([]byte, error)
 }
 
 // Client is the main object used to obtain credentials and interface with the
 // REST API.
+//
+// A Client and its devices are safe for concurrent use by multiple goroutines.
+// However, its exported fields must not be modified while it's in use, and
+// since Token and EndpointURL are updated when logging in again, they must be
+// read using Session (or observed using OnTokenChange) instead.
 type Client struct {
 	// Token represents an authentication token used for all calls to the API.
+	// While the client is in use, read it using Session.
 	Token string
 
 	// EndpointURL is the URL to the given API endpoint all calls will be sent to.
+	// While the client is in use, read it using Session.
 	EndpointURL string
 
-	client  endpoint
-	devices map[string]*Device
+	// Credentials, if set, are used to log in again when the server rejects the
+	// current token, after which the failed request is retried once.
+	Credentials *Credentials
+
+	// CredentialsFunc, if set, is called to obtain the credentials used to log
+	// in again when the server rejects the current token. It takes precedence
+	// over Credentials.
+	CredentialsFunc func(ctx context.Context) (*Credentials, error)
+
+	// OnTokenChange, if set, is called with the new token after every successful
+	// login, including the ones done automatically when the token expires.
+	OnTokenChange func(token string)
+
+	// TokenStore, if set, is used by Login to resume a saved session instead of
+	// logging in from scratch, and to save the session after every successful
+	// login.
+	TokenStore TokenStore
+
+	client         endpoint
+	logger         Logger
+	refreshAfterDo bool
+	pageSize       int
+
+	// mu guards Token, EndpointURL, location, devices and lowBattery.
+	mu         sync.RWMutex
+	location   *time.Location
+	devices    map[string]*Device
+	lowBattery *batteryAlert
+
+	// loginMu is held while logging in again, so concurrent requests that fail
+	// due to the same expired token only cause a single login.
+	loginMu sync.Mutex
 }
 
 // NewClient returns a new client that's ready to use the Login method or use
-// a saved token.
-func NewClient() *Client {
-	return &Client{client: &httpClient{}}
+// a saved token. The client can be configured by passing any number of
+// options.
+func NewClient(opts ...Option) *Client {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := &Client{
+		client: &httpClient{
+			client:  o.httpClient,
+			headers: o.requestHeaders(),
+			retry:   o.retry,
+			logger:  o.logger,
+		},
+		logger:         o.logger,
+		refreshAfterDo: o.refreshAfterDo,
+		pageSize:       o.pageSize,
+	}
+	if o.endpointURL != "" {
+		c.EndpointURL = trailingSlash(o.endpointURL)
+	}
+	return c
 }
 
 // Login uses the given credentials object to authenticate and obtain a token
 // and an endpoint URL. It will also load the initial list of devices.
+//
+// If the client has a TokenStore, a session saved for the same username is
+// tried first and a new login is only done if it can't be used, in which case
+// the reason is logged. The new session is then saved, and an error is returned
+// if that fails, even though the client is logged in.
 func (c *Client) Login(creds *Credentials) error {
-	credsJSON, err := creds.toJSON(false, true)
+	return c.LoginContext(context.Background(), creds)
+}
+
+// LoginContext is like Login, but the request is bound to the given context.
+func (c *Client) LoginContext(ctx context.Context, creds *Credentials) error {
+	if c.TokenStore != nil && c.resumeSession(ctx, creds) {
+		return nil
+	}
+	return c.login(ctx, creds)
+}
+
+func (c *Client) login(ctx context.Context, creds *Credentials) error {
+	if creds == nil {
+		return ErrNoCredentials
+	}
+	credsJSON, err := creds.toJSON(true, true)
 	if err != nil {
 		return err
 	}
-	resp, err := c.client.PostJSON(creds.URL, credsJSON, "")
+	resp, err := c.client.PostJSON(ctx, creds.URL, credsJSON, "")
 	if err != nil {
 		return err
 	}
@@ -64,41 +157,220 @@ func (c *Client) Login(creds *Credentials) error {
 		return err
 	}
 
-	c.Token = auth.Token
-	c.EndpointURL = trailingSlash(auth.Platform.Endpoint)
+	endpointURL := trailingSlash(auth.Platform.Endpoint)
+	c.setSession(auth.Token, endpointURL)
+	c.setTimezone(auth.User.Timezone)
+	if c.OnTokenChange != nil {
+		c.OnTokenChange(auth.Token)
+	}
+
+	// Only hubs are taken from the list of physical devices, since the other
+	// devices are already present in the list of products.
+	c.parseDevices(append(auth.Products, hubsOf(auth.Devices)...))
 
-	c.parseDevices(auth.Products)
+	if c.TokenStore != nil {
+		session := &Session{
+			Username:    creds.Username,
+			Token:       auth.Token,
+			EndpointURL: endpointURL,
+			Timezone:    auth.User.Timezone,
+		}
+		if err := c.TokenStore.Save(session); err != nil {
+			return fmt.Errorf("saving session: %w", err)
+		}
+	}
 	return nil
 }
 
+// resumeSession loads the saved session and, if it belongs to the user with the
+// given credentials, checks it's still valid by loading the list of devices
+// with it. It returns true if the client is now using the saved session.
+func (c *Client) resumeSession(ctx context.Context, creds *Credentials) bool {
+	session, err := c.TokenStore.Load()
+	if err != nil {
+		logf(c.logger, "loading saved session failed, logging in again: %v", err)
+		return false
+	}
+	if session == nil || session.Token == "" || session.EndpointURL == "" {
+		return false
+	}
+	if creds != nil && session.Username != creds.Username {
+		return false
+	}
+
+	endpointURL := trailingSlash(session.EndpointURL)
+	if err := c.refreshDevices(ctx, session.Token, endpointURL); err != nil {
+		logf(c.logger, "saved session can't be used, logging in again: %v", err)
+		return false
+	}
+	c.setSession(session.Token, endpointURL)
+	c.setTimezone(session.Timezone)
+
+	// Without hubs, devices have no parents, but the session can still be used.
+	hubs, err := c.fetchHubs(ctx, session.Token, endpointURL)
+	if err != nil {
+		logf(c.logger, "loading hubs failed, keeping the ones already loaded: %v", err)
+		return true
+	}
+	c.parseDevices(hubs)
+	return true
+}
+
+// Session returns the token and endpoint URL currently used by the client. It's
+// safe to call while the client may be logging in again.
+func (c *Client) Session() (token string, endpointURL string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Token, c.EndpointURL
+}
+
+func (c *Client) setSession(token string, endpointURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Token = token
+	c.EndpointURL = endpointURL
+}
+
+// setTimezone sets the location of the user's home to the time zone with the
+// given name, leaving it unknown if the name is invalid.
+func (c *Client) setTimezone(name string) {
+	location, err := time.LoadLocation(name)
+	if name == "" || err != nil {
+		location = nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.location = location
+}
+
+// Location returns the time zone of the user's home as reported when logging
+// in, or time.Local if it's unknown. Schedules should be evaluated in it.
+func (c *Client) Location() *time.Location {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.location == nil {
+		return time.Local
+	}
+	return c.location
+}
+
 // RefreshDevices updates the devices available and their current states.
+// Devices that are no longer present are removed. Hubs are only loaded when
+// logging in, so they're kept as they are.
 func (c *Client) RefreshDevices() error {
-	url := c.buildURL(refreshDevicesTarget)
-	resp, err := c.client.Get(url, c.Token)
+	return c.RefreshDevicesContext(context.Background())
+}
+
+// RefreshDevicesContext is like RefreshDevices, but the request is bound to the
+// given context.
+func (c *Client) RefreshDevicesContext(ctx context.Context) error {
+	return c.withAuthRetry(ctx, c.refreshDevices)
+}
+
+func (c *Client) refreshDevices(ctx context.Context, token string, endpointURL string) error {
+	devices, err := c.fetchProducts(ctx, token, endpointURL, "")
 	if err != nil {
 		return err
 	}
+	c.parseDevices(devices)
+	c.pruneDevices(devices)
+	return nil
+}
 
+// fetchHubs loads the hubs from the list of physical devices. The other
+// physical devices are already present in the list of products. Hubs aren't
+// part of the list of products, so they're only loaded when logging in or
+// resuming a session.
+func (c *Client) fetchHubs(ctx context.Context, token string, endpointURL string) ([]jsonEntity, error) {
+	resp, err := c.client.Get(ctx, buildURL(endpointURL, hubsTarget), token)
+	if err != nil {
+		return nil, err
+	}
 	var devices []jsonEntity
 	if err := json.Unmarshal(resp, &devices); err != nil {
-		return err
+		return nil, err
 	}
+	return hubsOf(devices), nil
+}
 
-	c.parseDevices(devices)
-	return nil
+// RefreshDevicesAfter updates only the devices that come after the device with
+// the given ID in the list of products, leaving the others untouched. Devices
+// that are no longer present aren't removed.
+func (c *Client) RefreshDevicesAfter(id string) error {
+	return c.RefreshDevicesAfterContext(context.Background(), id)
+}
+
+// RefreshDevicesAfterContext is like RefreshDevicesAfter, but the request is
+// bound to the given context.
+func (c *Client) RefreshDevicesAfterContext(ctx context.Context, id string) error {
+	return c.withAuthRetry(ctx, func(ctx context.Context, token string, endpointURL string) error {
+		devices, err := c.fetchProducts(ctx, token, endpointURL, id)
+		if err != nil {
+			return err
+		}
+		c.parseDevices(devices)
+		return nil
+	})
+}
+
+// fetchProducts loads the products that come after the one with the given ID,
+// or all of them if it's empty. If the client has a page size, pages are
+// requested until one comes back with fewer products than that.
+func (c *Client) fetchProducts(ctx context.Context, token string, endpointURL string, after string) ([]jsonEntity, error) {
+	var result []jsonEntity
+	for {
+		target := buildURL(endpointURL, refreshDevicesTarget+url.QueryEscape(after))
+		resp, err := c.client.Get(ctx, target, token)
+		if err != nil {
+			return nil, err
+		}
+
+		var devices []jsonEntity
+		if err := json.Unmarshal(resp, &devices); err != nil {
+			return nil, err
+		}
+		result = append(result, devices...)
+
+		if c.pageSize <= 0 || len(devices) < c.pageSize {
+			return result, nil
+		}
+		last := devices[len(devices)-1].ID
+		if last == "" || last == after {
+			return result, nil
+		}
+		after = last
+	}
+}
+
+// RefreshDevice updates the state of the device with the given ID, without
+// loading any other devices. It returns ErrDeviceNotFound if the client doesn't
+// know about the device yet.
+func (c *Client) RefreshDevice(id string) error {
+	return c.RefreshDeviceContext(context.Background(), id)
+}
+
+// RefreshDeviceContext is like RefreshDevice, but the request is bound to the
+// given context.
+func (c *Client) RefreshDeviceContext(ctx context.Context, id string) error {
+	device := c.Device(id)
+	if device == nil {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, id)
+	}
+	return c.fetchDevice(ctx, device)
 }
 
 // Device returns the device with the given ID or null if no such device could
 // be found.
 func (c *Client) Device(id string) *Device {
-	if c.devices == nil {
-		return nil
-	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.devices[id]
 }
 
 // Devices returns a slice with all the devices present.
 func (c *Client) Devices() []*Device {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	if c.devices == nil {
 		return nil
 	}
@@ -111,37 +383,148 @@ func (c *Client) Devices() []*Device {
 }
 
 func (c *Client) parseDevices(devices []jsonEntity) {
+	c.mu.Lock()
 	if c.devices == nil {
 		c.devices = make(map[string]*Device, len(devices))
 	}
 
+	alert := c.lowBattery
+	var lowBattery []*Device
 	for i := range devices {
-		id := devices[i].ID
-		device := c.Device(id)
+		entity := &devices[i]
+		device := c.devices[entity.ID]
+		var previous *jsonEntity
 		if device != nil {
-			device.entity = &devices[i]
+			previous = device.snapshot()
+			device.setEntity(entity)
 		} else {
-			c.devices[id] = &Device{
-				entity: &devices[i],
+			device = &Device{
+				entity: entity,
 				client: c,
 			}
+			c.devices[entity.ID] = device
 		}
+		if alert != nil && alert.crossed(previous, entity) {
+			lowBattery = append(lowBattery, device)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, device := range lowBattery {
+		alert.fn(device, device.BatteryLevel())
 	}
 }
 
-func (c *Client) modifyDeviceState(device *Device, state *jsonState) error {
+// pruneDevices removes the devices that aren't in the given complete list of
+// products. Hubs are kept, since they aren't in it.
+func (c *Client) pruneDevices(products []jsonEntity) {
+	present := make(map[string]bool, len(products))
+	for _, product := range products {
+		present[product.ID] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, device := range c.devices {
+		if !present[id] && !device.IsHub() {
+			delete(c.devices, id)
+		}
+	}
+}
+
+// updateDevice replaces the state of a single device, calling the low battery
+// callback if needed.
+func (c *Client) updateDevice(device *Device, entity *jsonEntity) {
+	c.mu.RLock()
+	alert := c.lowBattery
+	c.mu.RUnlock()
+
+	previous := device.snapshot()
+	device.setEntity(entity)
+	if alert != nil && alert.crossed(previous, entity) {
+		alert.fn(device, device.BatteryLevel())
+	}
+}
+
+func (c *Client) modifyDeviceState(ctx context.Context, device *Device, state *jsonState) error {
 	data, err := json.Marshal(state)
 	if err != nil {
 		return err
 	}
 
-	url := c.buildURL(deviceTarget, device.Type(), device.ID())
-	_, err = c.client.PostJSON(url, data, c.Token)
-	return err
+	return c.withAuthRetry(ctx, func(ctx context.Context, token string, endpointURL string) error {
+		// Setting the state is idempotent, so the request can safely be
+		// retried. Logging in again isn't, so only this request is marked.
+		target := buildURL(endpointURL, deviceTarget, device.Type(), device.ID())
+		_, err := c.client.PostJSON(withIdempotent(ctx), target, data, token)
+		return err
+	})
 }
 
-func (c *Client) buildURL(params ...string) string {
-	return c.EndpointURL + path.Join(params...)
+// fetchDevice loads the current state of a single device from the server.
+func (c *Client) fetchDevice(ctx context.Context, device *Device) error {
+	return c.withAuthRetry(ctx, func(ctx context.Context, token string, endpointURL string) error {
+		target := buildURL(endpointURL, deviceTarget, device.Type(), device.ID())
+		resp, err := c.client.Get(ctx, target, token)
+		if err != nil {
+			return err
+		}
+		entity, err := parseEntity(resp)
+		if err != nil {
+			return err
+		}
+		if entity.ID != device.ID() {
+			return fmt.Errorf("requested device %s, got %s", device.ID(), entity.ID)
+		}
+		c.updateDevice(device, entity)
+		return nil
+	})
+}
+
+// withAuthRetry performs the given request with the current token and endpoint
+// URL and, if the server rejects the token and credentials are available, logs
+// in again and retries the request once.
+func (c *Client) withAuthRetry(ctx context.Context, request func(ctx context.Context, token string, endpointURL string) error) error {
+	token, endpointURL := c.Session()
+	err := request(ctx, token, endpointURL)
+	if err == nil || !isAuthError(err) {
+		return err
+	}
+	if c.Credentials == nil && c.CredentialsFunc == nil {
+		return err
+	}
+	if err := c.relogin(ctx, token, err); err != nil {
+		return err
+	}
+	token, endpointURL = c.Session()
+	return request(ctx, token, endpointURL)
+}
+
+// relogin logs in again, unless the rejected token was already replaced by
+// another request while waiting for the lock.
+func (c *Client) relogin(ctx context.Context, rejected string, reason error) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	if token, _ := c.Session(); token != rejected {
+		return nil
+	}
+
+	logf(c.logger, "token rejected, logging in again: %v", reason)
+	creds := c.Credentials
+	if c.CredentialsFunc != nil {
+		var err error
+		if creds, err = c.CredentialsFunc(ctx); err != nil {
+			return fmt.Errorf("obtaining credentials to log in again: %w", err)
+		}
+	}
+	if err := c.login(ctx, creds); err != nil {
+		return fmt.Errorf("logging in again after token was rejected: %w", err)
+	}
+	return nil
+}
+
+func buildURL(endpointURL string, params ...string) string {
+	return endpointURL + path.Join(params...)
 }
 
 // Credentials contains the user's username, password and URL used to perform
@@ -201,6 +584,23 @@ func parseSession(data []byte) (*jsonSession, error) {
 	return &session, nil
 }
 
+// parseEntity parses a single device, which the server may send either on its
+// own or as the only element of a list.
+func parseEntity(data []byte) (*jsonEntity, error) {
+	var entity jsonEntity
+	if err := json.Unmarshal(data, &entity); err == nil {
+		return &entity, nil
+	}
+	var entities []jsonEntity
+	if err := json.Unmarshal(data, &entities); err != nil {
+		return nil, err
+	}
+	if len(entities) != 1 {
+		return nil, fmt.Errorf("expected a single device, got %d", len(entities))
+	}
+	return &entities[0], nil
+}
+
 func trailingSlash(input string) string {
 	return strings.TrimRight(input, "/") + "/"
 }
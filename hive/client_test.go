@@ -1,27 +1,48 @@
 package hive
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type mockEndpoint struct {
+	mu      sync.Mutex
 	url     string
 	token   string
 	payload string
 	result  string
 	err     error
+
+	// handle, if set, overrides result and err based on the request.
+	handle func(url string, token string) (string, error)
 }
 
-func (c *mockEndpoint) PostJSON(url string, jsonStr []byte, token string) ([]byte, error) {
+func (c *mockEndpoint) PostJSON(ctx context.Context, url string, jsonStr []byte, token string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.url = url
 	c.token = token
 	c.payload = string(jsonStr)
+	if c.handle != nil {
+		result, err := c.handle(url, token)
+		return []byte(result), err
+	}
 	return []byte(c.result), c.err
 }
 
-func (c *mockEndpoint) Get(url string, token string) ([]byte, error) {
-	return c.PostJSON(url, nil, token)
+func (c *mockEndpoint) Get(ctx context.Context, url string, token string) ([]byte, error) {
+	return c.PostJSON(ctx, url, nil, token)
 }
 
 func (c *mockEndpoint) parsePayload() map[string]interface{} {
@@ -106,3 +127,305 @@ func TestRefreshDevices(t *testing.T) {
 		t.Error("Device 01234567-abcd not found after parsing")
 	}
 }
+
+func TestRefreshDevice(t *testing.T) {
+	mock := &mockEndpoint{}
+	client := &Client{client: mock, EndpointURL: "https://example.com/"}
+	client.parseDevices([]jsonEntity{
+		{ID: "12345678-abcd", Type: "motionsensor"},
+		{ID: "01234567-abcd", Type: "warmwhitelight"},
+	})
+
+	mock.result = `{"id":"12345678-abcd","type":"motionsensor","props":{"motion":{"status":true}}}`
+	if err := client.RefreshDevice("12345678-abcd"); err != nil {
+		t.Fatalf("client.RefreshDevice returned error: %v", err)
+	}
+	if want := "https://example.com/nodes/motionsensor/12345678-abcd"; mock.url != want {
+		t.Errorf("RefreshDevice requested %q, want %q", mock.url, want)
+	}
+	if !client.Device("12345678-abcd").HasMotion() {
+		t.Error("device has no motion after refreshing it")
+	}
+
+	mock.result = `[{"id":"01234567-abcd","type":"warmwhitelight"}]`
+	if err := client.Device("12345678-abcd").Refresh(); err == nil {
+		t.Error("Refresh returned no error when the server sent a different device")
+	}
+	if err := client.RefreshDevice("missing"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("RefreshDevice for unknown device returned %v, want %v", err, ErrDeviceNotFound)
+	}
+}
+
+func TestRefreshDevicesPaginated(t *testing.T) {
+	var requested []string
+	mock := &mockEndpoint{handle: func(url string, token string) (string, error) {
+		requested = append(requested, url)
+		switch url {
+		case "https://example.com/products?after=":
+			return `[{"id":"a","type":"warmwhitelight"},{"id":"b","type":"warmwhitelight"}]`, nil
+		case "https://example.com/products?after=b":
+			return `[{"id":"c","type":"warmwhitelight"}]`, nil
+		}
+		return "", fmt.Errorf("unexpected request to %s", url)
+	}}
+	client := NewClient(WithPageSize(2), WithEndpointURL("https://example.com"))
+	client.client = mock
+
+	if err := client.RefreshDevices(); err != nil {
+		t.Fatalf("client.RefreshDevices returned error: %v", err)
+	}
+	if len(requested) != 2 || len(client.Devices()) != 3 {
+		t.Errorf("RefreshDevices made requests %q and parsed %d devices, want 2 requests and 3 devices",
+			requested, len(client.Devices()))
+	}
+
+	requested = nil
+	if err := client.RefreshDevicesAfter("b"); err != nil {
+		t.Fatalf("client.RefreshDevicesAfter returned error: %v", err)
+	}
+	if len(requested) != 1 || len(client.Devices()) != 3 {
+		t.Errorf("RefreshDevicesAfter made requests %q and left %d devices, want 1 request and 3 devices",
+			requested, len(client.Devices()))
+	}
+}
+
+func TestContextCanceled(t *testing.T) {
+	mock := &mockEndpoint{}
+	client := &Client{client: mock}
+	creds := &Credentials{"user", "secret", "http://example.com/"}
+	mock.result = `{"token": "1234567890", "platform": {"endpoint": "https://example.com/"}}`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.LoginContext(ctx, creds); err != context.Canceled {
+		t.Errorf("client.LoginContext returned %v, want %v", err, context.Canceled)
+	}
+	if err := client.RefreshDevicesContext(ctx); err != context.Canceled {
+		t.Errorf("client.RefreshDevicesContext returned %v, want %v", err, context.Canceled)
+	}
+	if mock.url != "" {
+		t.Errorf("endpoint requested %q after context was canceled", mock.url)
+	}
+}
+
+func TestRelogin(t *testing.T) {
+	mock := &mockEndpoint{}
+	creds := &Credentials{"user", "secret", "http://example.com/login"}
+	client := &Client{client: mock, Token: "expired", EndpointURL: "http://example.com/", Credentials: creds}
+
+	var rotated []string
+	client.OnTokenChange = func(token string) {
+		rotated = append(rotated, token)
+	}
+	mock.handle = func(url string, token string) (string, error) {
+		if url == creds.URL {
+			return `{"token": "fresh", "platform": {"endpoint": "http://example.com/"}}`, nil
+		}
+		if token != "fresh" {
+			return "", &APIError{StatusCode: http.StatusUnauthorized}
+		}
+		return `[{"id":"12345678-abcd","type":"warmwhitelight"}]`, nil
+	}
+
+	if err := client.RefreshDevices(); err != nil {
+		t.Errorf("client.RefreshDevices returned error: %v", err)
+	}
+	if token, _ := client.Session(); token != "fresh" {
+		t.Errorf("client has token %q after login, want %q", token, "fresh")
+	}
+	if len(rotated) != 1 || rotated[0] != "fresh" {
+		t.Errorf("OnTokenChange called with %v, want [fresh]", rotated)
+	}
+	if client.Device("12345678-abcd") == nil {
+		t.Error("Device 12345678-abcd not found after retrying request")
+	}
+}
+
+func TestReloginNoCredentials(t *testing.T) {
+	mock := &mockEndpoint{err: &APIError{StatusCode: http.StatusUnauthorized}}
+	client := &Client{client: mock, Token: "expired"}
+
+	err := client.RefreshDevices()
+	if !isAuthError(err) {
+		t.Errorf("client.RefreshDevices returned %v, want the original authentication error", err)
+	}
+}
+
+func TestLoginResumesSession(t *testing.T) {
+	mock := &mockEndpoint{}
+	store := &MemoryTokenStore{}
+	store.Save(&Session{"user", "saved", "https://example.com/api", ""})
+	client := &Client{client: mock, TokenStore: store}
+	creds := &Credentials{"user", "secret", "http://example.com/login"}
+
+	logins := 0
+	mock.handle = func(url string, token string) (string, error) {
+		if url == creds.URL {
+			logins++
+			return `{"token": "fresh", "platform": {"endpoint": "https://example.com/api"}}`, nil
+		}
+		if token != "saved" && token != "fresh" {
+			return "", &APIError{StatusCode: http.StatusUnauthorized}
+		}
+		return `[{"id":"12345678-abcd","type":"warmwhitelight"}]`, nil
+	}
+
+	if err := client.Login(creds); err != nil {
+		t.Errorf("client.Login returned error: %v", err)
+	}
+	if logins != 0 || client.Token != "saved" {
+		t.Errorf("client logged in %d times and has token %q, want saved session to be used", logins, client.Token)
+	}
+	if client.Device("12345678-abcd") == nil {
+		t.Error("Device 12345678-abcd not found after resuming session")
+	}
+
+	store.Save(&Session{"user", "expired", "https://example.com/api", ""})
+	if err := client.Login(creds); err != nil {
+		t.Errorf("client.Login returned error: %v", err)
+	}
+	if logins != 1 || client.Token != "fresh" {
+		t.Errorf("client logged in %d times and has token %q, want a single new login", logins, client.Token)
+	}
+	session, _ := store.Load()
+	if session.Token != "fresh" || session.EndpointURL != "https://example.com/api/" {
+		t.Errorf("store has session %+v after login, want the new one", session)
+	}
+}
+
+type failingTokenStore struct{}
+
+func (failingTokenStore) Load() (*Session, error) { return nil, errors.New("permission denied") }
+func (failingTokenStore) Save(*Session) error     { return nil }
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestLoginLogsStoreError(t *testing.T) {
+	mock := &mockEndpoint{result: `{"token": "fresh", "platform": {"endpoint": "https://example.com/api"}}`}
+	logger := &recordingLogger{}
+	client := &Client{client: mock, TokenStore: failingTokenStore{}, logger: logger}
+
+	if err := client.Login(&Credentials{"user", "secret", "http://example.com/login"}); err != nil {
+		t.Fatalf("client.Login returned error: %v", err)
+	}
+	if len(logger.lines) != 1 || !strings.Contains(logger.lines[0], "permission denied") {
+		t.Errorf("client logged %q, want the error loading the session", logger.lines)
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	client := NewClient(
+		WithHTTPClient(server.Client()),
+		WithEndpointURL(server.URL),
+		WithUserAgent("hivecli/1.0"),
+		WithOrigin("https://example.com"),
+		WithBaseHeaders(http.Header{"x-custom": {"value"}}),
+	)
+	if client.EndpointURL != server.URL+"/" {
+		t.Errorf("client has endpoint %q, want %q", client.EndpointURL, server.URL+"/")
+	}
+	if err := client.RefreshDevices(); err != nil {
+		t.Fatalf("client.RefreshDevices returned error: %v", err)
+	}
+	want := map[string]string{
+		"User-Agent":   "hivecli/1.0",
+		"Origin":       "https://example.com",
+		"X-Custom":     "value",
+		"Content-Type": "application/json",
+	}
+	for key, value := range want {
+		if headers.Get(key) != value {
+			t.Errorf("request has header %s set to %q, want %q", key, headers.Get(key), value)
+		}
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	mock := &mockEndpoint{}
+	creds := &Credentials{"user", "secret", "http://example.com/login"}
+	client := &Client{client: mock, Token: "expired", Credentials: creds}
+
+	logins := 0
+	mock.handle = func(url string, token string) (string, error) {
+		if url == creds.URL {
+			logins++
+			return `{"token": "fresh", "platform": {"endpoint": "http://example.com/"}}`, nil
+		}
+		if token != "fresh" {
+			return "", &APIError{StatusCode: http.StatusUnauthorized}
+		}
+		return `[
+			{"id":"12345678-abcd","type":"warmwhitelight","state":{"status":"ON"}},
+			{"id":"01234567-abcd","type":"colourtuneablelight","state":{"status":"OFF"}}
+		]`, nil
+	}
+	if err := client.RefreshDevices(); err != nil {
+		t.Fatalf("client.RefreshDevices returned error: %v", err)
+	}
+	client.setSession("expired", "http://example.com/")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if err := client.RefreshDevices(); err != nil {
+				t.Errorf("client.RefreshDevices returned error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for _, device := range client.Devices() {
+				_ = device.IsOn()
+				_ = device.String()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := client.Device("12345678-abcd").Do(NewChange().TurnOff()); err != nil {
+				t.Errorf("device.Do returned error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if logins != 2 {
+		t.Errorf("client logged in %d times, want 2", logins)
+	}
+}
+
+func TestLocation(t *testing.T) {
+	mock := &mockEndpoint{}
+	client := &Client{client: mock}
+	if client.Location() != time.Local {
+		t.Errorf("client.Location returned %v before login, want local time zone", client.Location())
+	}
+
+	mock.result = `{
+		"token": "1234567890",
+		"user": {"timezone": "Europe/London"},
+		"platform": {"endpoint": "https://example.com/"}
+	}`
+	if err := client.Login(&Credentials{"user", "secret", "http://example.com/"}); err != nil {
+		t.Fatalf("client.Login returned error: %v", err)
+	}
+	if _, err := time.LoadLocation("Europe/London"); err == nil && client.Location().String() != "Europe/London" {
+		t.Errorf("client.Location returned %v, want Europe/London", client.Location())
+	}
+}
@@ -1,18 +1,55 @@
 package hive
 
+import "time"
+
 const (
 	typeMotionSensor   = "motionsensor"
 	typeColourLight    = "colourtuneablelight"
 	typeWarmWhiteLight = "warmwhitelight"
+	typeTuneableLight  = "tuneablelight"
+	typeHeating        = "heating"
+	typeTRV            = "trvcontrol"
+	typeHotWater       = "hotwater"
+	typePlug           = "activeplug"
+	typeContactSensor  = "contactsensor"
+	typeHub            = "hub"
 
 	colorCold = 6535
 	colorWarm = 2700
+
+	// Limits of the target temperature of heating, shared by the thermostat
+	// and radiator valves.
+	heatingMinTemperature = 5.0
+	heatingMaxTemperature = 32.0
+
+	boostMaxDuration = 6 * time.Hour
+)
+
+// Mode is the mode of operation of a device, which determines whether it
+// follows its schedule.
+type Mode string
+
+const (
+	// ModeSchedule makes the device follow its schedule.
+	ModeSchedule Mode = "SCHEDULE"
+
+	// ModeManual makes the device keep the state it was manually set to. For
+	// hot water, it means the water is always kept hot.
+	ModeManual Mode = "MANUAL"
+
+	// ModeOff keeps the device off. For heating, frost protection still applies.
+	ModeOff Mode = "OFF"
+
+	// ModeBoost temporarily overrides the device state for a set duration.
+	ModeBoost Mode = "BOOST"
 )
 
 var (
 	statusON  = "ON"
 	statusOFF = "OFF"
 
+	statusOPEN = "OPEN"
+
 	colourModeWHITE  = "WHITE"
 	colourModeCOLOUR = "COLOUR"
 
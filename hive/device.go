@@ -1,39 +1,90 @@
 package hive
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 )
 
 // Device represents a reference to a single device. It can be a light, sensor
 // thermostat or anything else.
 type Device struct {
-	entity *jsonEntity
 	client *Client
+
+	// mu guards entity. The entity itself is never modified once set, so it can
+	// be read without holding the lock after obtaining it via snapshot.
+	mu     sync.RWMutex
+	entity *jsonEntity
 }
 
-// Do sends the request to apply the given change to this device.
+// Do sends the request to apply the given change to this device. If the change
+// has validation errors or uses capabilities the device doesn't have, an error
+// is returned without sending the request.
+//
+// Once the change is applied, the state of the device is updated to reflect it
+// without waiting for the next refresh. If the client was created with
+// WithRefreshAfterDo, the device is fetched from the server instead; if that
+// fails, the failure is logged and the change is applied to the state the client
+// already has, so an error always means the change wasn't applied.
 func (d *Device) Do(c *Change) error {
-	return d.client.modifyDeviceState(d, &c.state)
+	return d.DoContext(context.Background(), c)
+}
+
+// DoContext is like Do, but the request is bound to the given context.
+func (d *Device) DoContext(ctx context.Context, c *Change) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	state := c.stateFor(d.snapshot())
+	if err := d.checkSupported(state); err != nil {
+		return err
+	}
+	if err := d.client.modifyDeviceState(ctx, d, state); err != nil {
+		return err
+	}
+	if d.client.refreshAfterDo {
+		err := d.client.fetchDevice(ctx, d)
+		if err == nil {
+			return nil
+		}
+		// The change was applied, so failing to fetch the device isn't an error.
+		logf(d.client.logger, "fetching %s after applying change failed: %v", d.ID(), err)
+	}
+	d.applyState(state)
+	return nil
+}
+
+// Refresh updates the state of this device from the server, without loading
+// any other devices.
+func (d *Device) Refresh() error {
+	return d.RefreshContext(context.Background())
+}
+
+// RefreshContext is like Refresh, but the request is bound to the given
+// context.
+func (d *Device) RefreshContext(ctx context.Context) error {
+	return d.client.fetchDevice(ctx, d)
 }
 
 // ID returns the unique ID of this device.
 func (d *Device) ID() string {
-	return d.entity.ID
+	return d.snapshot().ID
 }
 
 // Type returns the type of this device.
 func (d *Device) Type() string {
-	return d.entity.Type
+	return d.snapshot().Type
 }
 
 // Name returns the user-given name of this device, or an empty string if no
 // name is given.
 func (d *Device) Name() string {
-	if d.entity.State.Name == nil {
+	e := d.snapshot()
+	if e.State.Name == nil {
 		return ""
 	}
-	return *d.entity.State.Name
+	return *e.State.Name
 }
 
 // String returns a string representation of this device, containing the ID,
@@ -48,18 +99,19 @@ func (d *Device) String() string {
 
 // Created returns the time when this device was added.
 func (d *Device) Created() time.Time {
-	return time.Time(d.entity.Created)
+	return time.Time(d.snapshot().Created)
 }
 
 // LastSeen returns the time when this device was last online.
 func (d *Device) LastSeen() time.Time {
-	return time.Time(d.entity.LastSeen)
+	return time.Time(d.snapshot().LastSeen)
 }
 
 // IsOnline returns true if this device is currently powered on and connected,
 // false otherwise.
 func (d *Device) IsOnline() bool {
-	return d.entity.Props.Online != nil && *d.entity.Props.Online
+	e := d.snapshot()
+	return e.Props.Online != nil && *e.Props.Online
 }
 
 // Getters specific to motion sensors
@@ -72,35 +124,72 @@ func (d *Device) IsMotionSensor() bool {
 // HasMotion returns true if this device is a motion sensor and is currently
 // detecting motion.
 func (d *Device) HasMotion() bool {
-	if d.entity.Props.Motion == nil {
+	e := d.snapshot()
+	if e.Props.Motion == nil {
 		return false
 	}
-	return d.entity.Props.Motion.Status
+	return e.Props.Motion.Status
 }
 
 // LastMotionStart returns the start time of the last detected motion by this
 // device, if it's a motion sensor.
 func (d *Device) LastMotionStart() time.Time {
-	if d.entity.Props.Motion == nil {
+	e := d.snapshot()
+	if e.Props.Motion == nil {
 		return time.Time{}
 	}
-	return time.Time(d.entity.Props.Motion.Start)
+	return time.Time(e.Props.Motion.Start)
 }
 
 // LastMotionEnd returns the end time of the last detected motion by this
 // device, if it's a motion sensor.
 func (d *Device) LastMotionEnd() time.Time {
-	if d.entity.Props.Motion == nil {
+	e := d.snapshot()
+	if e.Props.Motion == nil {
+		return time.Time{}
+	}
+	return time.Time(e.Props.Motion.End)
+}
+
+// Getters specific to contact sensors
+
+// IsContactSensor checks if this device is a window or door contact sensor.
+func (d *Device) IsContactSensor() bool {
+	return d.Type() == typeContactSensor
+}
+
+// IsOpen returns true if this device is a contact sensor and the window or door
+// it's attached to is currently open.
+func (d *Device) IsOpen() bool {
+	e := d.snapshot()
+	return e.Props.Status != nil && *e.Props.Status == statusOPEN
+}
+
+// LastOpened returns the time the window or door this contact sensor is
+// attached to was last opened.
+func (d *Device) LastOpened() time.Time {
+	e := d.snapshot()
+	if e.Props.Contact == nil {
 		return time.Time{}
 	}
-	return time.Time(d.entity.Props.Motion.End)
+	return time.Time(e.Props.Contact.Opened)
+}
+
+// LastClosed returns the time the window or door this contact sensor is
+// attached to was last closed.
+func (d *Device) LastClosed() time.Time {
+	e := d.snapshot()
+	if e.Props.Contact == nil {
+		return time.Time{}
+	}
+	return time.Time(e.Props.Contact.Closed)
 }
 
 // Getters specific to lights
 
 // IsLight returns true if this device is a light bulb of any kind.
 func (d *Device) IsLight() bool {
-	return d.Type() == typeWarmWhiteLight || d.IsColorLight()
+	return d.Type() == typeWarmWhiteLight || d.Type() == typeTuneableLight || d.IsColorLight()
 }
 
 // IsColorLight returns true if this device is a color light bulb.
@@ -108,31 +197,35 @@ func (d *Device) IsColorLight() bool {
 	return d.Type() == typeColourLight
 }
 
-// IsOn returns true if this device is a light bulb and is currently turned on.
+// IsOn returns true if this device is a light bulb or plug and is currently
+// turned on, or a hot water device and is currently heating water.
 func (d *Device) IsOn() bool {
-	return d.entity.State.Status != nil && *d.entity.State.Status == statusON
+	e := d.snapshot()
+	return e.State.Status != nil && *e.State.Status == statusON
 }
 
 // Brightness returns the current brightness level of this light bulb, between
 // 0 and 100.
 func (d *Device) Brightness() int {
-	if d.entity.State.Brightness == nil {
+	e := d.snapshot()
+	if e.State.Brightness == nil {
 		return 0
 	}
-	return *d.entity.State.Brightness
+	return *e.State.Brightness
 }
 
 // Color returns the current color set on this colored light bulb. Will return
 // the last used color if the device is not currently in color mode or turned
 // off.
 func (d *Device) Color() HSV {
-	if d.entity.State.Hue == nil || d.entity.State.Saturation == nil || d.entity.State.Value == nil {
+	e := d.snapshot()
+	if e.State.Hue == nil || e.State.Saturation == nil || e.State.Value == nil {
 		return HSV{}
 	}
 	return HSV{
-		*d.entity.State.Hue,
-		*d.entity.State.Saturation,
-		*d.entity.State.Value,
+		*e.State.Hue,
+		*e.State.Saturation,
+		*e.State.Value,
 	}
 }
 
@@ -140,10 +233,11 @@ func (d *Device) Color() HSV {
 // in kelvins. Will return the last temperature value if the device is off or
 // in color mode.
 func (d *Device) ColorTemperature() int {
-	if d.entity.State.ColourTemperature == nil {
+	e := d.snapshot()
+	if e.State.ColourTemperature == nil {
 		return 0
 	}
-	return *d.entity.State.ColourTemperature
+	return *e.State.ColourTemperature
 }
 
 // ColorTemperaturePercent returns the current temperature of this colored light
@@ -151,15 +245,214 @@ func (d *Device) ColorTemperature() int {
 // setting. Will return the last temperature value if the device is off or in
 // color mode.
 func (d *Device) ColorTemperaturePercent() int {
-	if d.entity.State.ColourTemperature == nil {
+	if d.snapshot().State.ColourTemperature == nil {
 		return 0
 	}
 	return temperatureToPercent(d.ColorTemperature())
 }
 
-func (d *Device) Mode() string {
-	if d.entity.State.Mode == nil {
+// Mode returns the current mode of this device, or an empty string if it
+// doesn't have one. Unlike HeatingMode and HotWaterMode, it returns ModeBoost
+// while the device is boosting.
+func (d *Device) Mode() Mode {
+	e := d.snapshot()
+	if e.State.Mode == nil {
+		return ""
+	}
+	return Mode(*e.State.Mode)
+}
+
+// snapshot returns the current state of the device.
+func (d *Device) snapshot() *jsonEntity {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.entity
+}
+
+func (d *Device) setEntity(entity *jsonEntity) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entity = entity
+}
+
+// Getters specific to plugs
+
+// IsPlug returns true if this device is a smart plug.
+func (d *Device) IsPlug() bool {
+	return d.Type() == typePlug
+}
+
+// PowerConsumption returns the power currently drawn through this plug, in
+// watts.
+func (d *Device) PowerConsumption() float64 {
+	e := d.snapshot()
+	if e.Props.PowerConsumption == nil {
+		return 0
+	}
+	return *e.Props.PowerConsumption
+}
+
+// Getters specific to heating
+
+// IsHeating returns true if this device controls heating, such as a thermostat
+// or a radiator valve.
+func (d *Device) IsHeating() bool {
+	return d.Type() == typeHeating || d.Type() == typeTRV
+}
+
+// CurrentTemperature returns the temperature measured by this heating device,
+// in degrees Celsius.
+func (d *Device) CurrentTemperature() float64 {
+	e := d.snapshot()
+	if e.Props.Temperature == nil {
+		return 0
+	}
+	return *e.Props.Temperature
+}
+
+// TargetTemperature returns the temperature this heating device is currently
+// heating to, in degrees Celsius.
+func (d *Device) TargetTemperature() float64 {
+	e := d.snapshot()
+	if e.State.Target == nil {
+		return 0
+	}
+	return *e.State.Target
+}
+
+// HeatingMode returns the mode of this heating device, which is one of
+// ModeSchedule, ModeManual or ModeOff. While boosting, it returns the mode the
+// device will return to once the boost ends.
+func (d *Device) HeatingMode() Mode {
+	return unboostedMode(d.snapshot())
+}
+
+// FrostProtection returns the temperature, in degrees Celsius, below which this
+// heating device turns on even when it's off.
+func (d *Device) FrostProtection() float64 {
+	e := d.snapshot()
+	if e.State.FrostProtection == nil {
+		return 0
+	}
+	return *e.State.FrostProtection
+}
+
+// Getters specific to hot water
+
+// IsHotWater returns true if this device controls hot water.
+func (d *Device) IsHotWater() bool {
+	return d.Type() == typeHotWater
+}
+
+// HotWaterMode returns the mode of this hot water device, which is one of
+// ModeSchedule, ModeManual (always on) or ModeOff. While boosting, it returns
+// the mode the device will return to once the boost ends.
+func (d *Device) HotWaterMode() Mode {
+	return unboostedMode(d.snapshot())
+}
+
+// Getters common to heating and hot water
+
+// IsBoosting returns true if this device is currently boosting.
+func (d *Device) IsBoosting() bool {
+	e := d.snapshot()
+	return e.State.Mode != nil && *e.State.Mode == string(ModeBoost)
+}
+
+// BoostRemaining returns how long the current boost will last, or 0 if this
+// device isn't boosting.
+func (d *Device) BoostRemaining() time.Duration {
+	e := d.snapshot()
+	if e.State.Mode == nil || *e.State.Mode != string(ModeBoost) || e.State.Boost == nil {
+		return 0
+	}
+	return time.Duration(*e.State.Boost) * time.Minute
+}
+
+// unboostedMode returns the mode of the device, or the mode it had before the
+// boost if it's currently boosting.
+func unboostedMode(e *jsonEntity) Mode {
+	if e.State.Mode == nil {
 		return ""
 	}
-	return *d.entity.State.Mode
+	mode := Mode(*e.State.Mode)
+	if mode != ModeBoost {
+		return mode
+	}
+	if prev := e.Props.Previous; prev != nil && prev.Mode != nil && *prev.Mode != string(ModeBoost) {
+		return Mode(*prev.Mode)
+	}
+	return ModeSchedule
+}
+
+// applyState updates the device as if the given state was successfully sent to
+// it. The entity is copied rather than modified, since it may be in use.
+func (d *Device) applyState(state *jsonState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entity := *d.entity
+	current := &entity.State
+
+	if state.Name != nil {
+		current.Name = copyString(state.Name)
+	}
+	if state.Status != nil {
+		current.Status = copyString(state.Status)
+	}
+	if state.Brightness != nil {
+		current.Brightness = copyInt(state.Brightness)
+	}
+	if state.Schedule != nil {
+		current.Schedule = state.Schedule
+	}
+
+	// Hue, saturation and value are kept when switching to color temperature
+	// and vice versa, like the server does, so they're only replaced when set.
+	if state.ColourMode != nil {
+		current.ColourMode = copyString(state.ColourMode)
+	}
+	if state.Hue != nil && state.Saturation != nil && state.Value != nil {
+		current.Hue = copyInt(state.Hue)
+		current.Saturation = copyInt(state.Saturation)
+		current.Value = copyInt(state.Value)
+	}
+	if state.ColourTemperature != nil {
+		current.ColourTemperature = copyInt(state.ColourTemperature)
+	}
+
+	if state.Mode != nil {
+		// The mode before a boost is remembered, so it can be cancelled later.
+		if *state.Mode == string(ModeBoost) && current.Mode != nil && *current.Mode != string(ModeBoost) {
+			entity.Props.Previous = &jsonPrevious{Mode: copyString(current.Mode), Target: current.Target}
+		}
+		current.Mode = copyString(state.Mode)
+		if *state.Mode != string(ModeBoost) {
+			current.Boost = nil
+		}
+	}
+	if state.Boost != nil {
+		current.Boost = copyInt(state.Boost)
+	}
+	if state.Target != nil {
+		target := *state.Target
+		current.Target = &target
+	}
+
+	d.entity = &entity
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
+
+func copyInt(i *int) *int {
+	if i == nil {
+		return nil
+	}
+	c := *i
+	return &c
 }
@@ -1,14 +1,26 @@
 package hive
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 )
 
+func parseTestDevice(t *testing.T, data string) *Device {
+	t.Helper()
+	var entity jsonEntity
+	if err := json.Unmarshal([]byte(data), &entity); err != nil {
+		t.Fatalf("failed to parse device: %v", err)
+	}
+	return &Device{client: &Client{client: &mockEndpoint{}}, entity: &entity}
+}
+
 func TestDoChange(t *testing.T) {
 	mock := &mockEndpoint{}
 	token := "abc"
 	client := &Client{client: mock, Token: token}
-	device := &Device{&jsonEntity{}, client}
+	device := &Device{client: client, entity: &jsonEntity{}}
 
 	device.Do(NewChange().TurnOn().Brightness(55))
 	if mock.token != token {
@@ -22,3 +34,189 @@ func TestDoChange(t *testing.T) {
 		t.Errorf("State brightness set to %f, want 55", payload["brightness"])
 	}
 }
+
+func TestHeating(t *testing.T) {
+	device := parseTestDevice(t, `{
+		"id": "12345678-abcd",
+		"type": "heating",
+		"props": {"temperature": 19.5, "previous": {"mode": "MANUAL", "target": 18}},
+		"state": {"mode": "BOOST", "target": 22, "boost": 45, "frostProtection": 7}
+	}`)
+
+	if !device.IsHeating() {
+		t.Error("IsHeating returned false for heating device")
+	}
+	if device.CurrentTemperature() != 19.5 {
+		t.Errorf("CurrentTemperature returned %v, want 19.5", device.CurrentTemperature())
+	}
+	if device.TargetTemperature() != 22 {
+		t.Errorf("TargetTemperature returned %v, want 22", device.TargetTemperature())
+	}
+	if device.FrostProtection() != 7 {
+		t.Errorf("FrostProtection returned %v, want 7", device.FrostProtection())
+	}
+	if device.HeatingMode() != ModeManual {
+		t.Errorf("HeatingMode returned %q, want mode before boost %q", device.HeatingMode(), ModeManual)
+	}
+	if !device.IsBoosting() || device.BoostRemaining() != 45*time.Minute {
+		t.Errorf("IsBoosting returned %v with %v remaining, want true with 45m", device.IsBoosting(), device.BoostRemaining())
+	}
+
+	mock := device.client.client.(*mockEndpoint)
+	device.Do(NewChange().CancelBoost())
+	payload := mock.parsePayload()
+	if payload["mode"] != string(ModeManual) || payload["target"] != 18.0 {
+		t.Errorf("CancelBoost sent mode %v and target %v, want %q and 18", payload["mode"], payload["target"], ModeManual)
+	}
+}
+
+func TestHotWater(t *testing.T) {
+	device := parseTestDevice(t, `{
+		"id": "12345678-abcd",
+		"type": "hotwater",
+		"props": {"previous": {"mode": "OFF"}},
+		"state": {"mode": "BOOST", "status": "ON", "boost": 30}
+	}`)
+
+	if !device.IsHotWater() || device.IsHeating() {
+		t.Error("hot water device not recognized as hot water only")
+	}
+	if device.HotWaterMode() != ModeOff {
+		t.Errorf("HotWaterMode returned %q, want mode before boost %q", device.HotWaterMode(), ModeOff)
+	}
+	if !device.IsOn() || device.BoostRemaining() != 30*time.Minute {
+		t.Errorf("IsOn returned %v with %v boost remaining, want true with 30m", device.IsOn(), device.BoostRemaining())
+	}
+
+	mock := device.client.client.(*mockEndpoint)
+	device.Do(NewChange().HotWaterBoost(time.Hour))
+	payload := mock.parsePayload()
+	if payload["mode"] != string(ModeBoost) || payload["boost"] != 60.0 {
+		t.Errorf("HotWaterBoost sent mode %v and boost %v, want %q and 60", payload["mode"], payload["boost"], ModeBoost)
+	}
+	if _, ok := payload["target"]; ok {
+		t.Error("HotWaterBoost sent a target temperature")
+	}
+}
+
+func TestPlug(t *testing.T) {
+	device := parseTestDevice(t, `{
+		"id": "12345678-abcd",
+		"type": "activeplug",
+		"props": {"powerConsumption": 62.5},
+		"state": {"status": "ON"}
+	}`)
+
+	if !device.IsPlug() || device.IsLight() {
+		t.Error("plug not recognized as plug only")
+	}
+	if !device.IsOn() {
+		t.Error("IsOn returned false for plug that is on")
+	}
+	if device.PowerConsumption() != 62.5 {
+		t.Errorf("PowerConsumption returned %v, want 62.5", device.PowerConsumption())
+	}
+}
+
+func TestContactSensor(t *testing.T) {
+	device := parseTestDevice(t, `{
+		"id": "12345678-abcd",
+		"type": "contactsensor",
+		"props": {"status": "OPEN", "contact": {"opened": 1500000000000, "closed": null}}
+	}`)
+
+	if !device.IsContactSensor() || device.IsMotionSensor() {
+		t.Error("contact sensor not recognized as contact sensor only")
+	}
+	if !device.IsOpen() {
+		t.Error("IsOpen returned false for open contact sensor")
+	}
+	if !device.LastOpened().Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("LastOpened returned %v, want %v", device.LastOpened(), time.Unix(1500000000, 0))
+	}
+	if !device.LastClosed().IsZero() {
+		t.Errorf("LastClosed returned %v, want zero time", device.LastClosed())
+	}
+}
+
+func TestDoMode(t *testing.T) {
+	device := parseTestDevice(t, `{"id": "12345678-abcd", "type": "warmwhitelight", "state": {"mode": "SCHEDULE"}}`)
+	if device.Mode() != ModeSchedule {
+		t.Errorf("Mode returned %q, want %q", device.Mode(), ModeSchedule)
+	}
+
+	mock := device.client.client.(*mockEndpoint)
+	if err := device.Do(NewChange().Mode(ModeManual)); err != nil {
+		t.Errorf("Do returned error for supported mode: %v", err)
+	}
+	if payload := mock.parsePayload(); payload["mode"] != string(ModeManual) {
+		t.Errorf("State mode set to %v, want %q", payload["mode"], ModeManual)
+	}
+
+	mock.payload = ""
+	if err := device.Do(NewChange().Mode(ModeOff)); !errors.Is(err, ErrModeNotSupported) {
+		t.Errorf("Do returned %v for unsupported mode, want %v", err, ErrModeNotSupported)
+	}
+	if mock.payload != "" {
+		t.Error("Do sent a request for an unsupported mode")
+	}
+}
+
+func TestDoUpdatesState(t *testing.T) {
+	device := parseTestDevice(t, `{
+		"id": "12345678-abcd",
+		"type": "colourtuneablelight",
+		"state": {"status": "OFF", "colourMode": "WHITE", "colourTemperature": 2700, "hue": 10, "saturation": 20, "value": 30}
+	}`)
+
+	if err := device.Do(NewChange().TurnOn().Color(ColorRed)); err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	if !device.IsOn() || device.Color() != ColorRed || device.ColorTemperature() != 2700 {
+		t.Errorf("device has status %v, color %v and temperature %d after Do, want on, %v and 2700",
+			device.IsOn(), device.Color(), device.ColorTemperature(), ColorRed)
+	}
+
+	mock := device.client.client.(*mockEndpoint)
+	mock.result = `{"id": "12345678-abcd", "type": "colourtuneablelight", "state": {"status": "OFF"}}`
+	device.client.refreshAfterDo = true
+	if err := device.Do(NewChange().TurnOn()); err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	if device.IsOn() {
+		t.Error("device is on after Do, want the state returned by the server")
+	}
+
+	mock.handle = func(url string, token string) (string, error) {
+		if mock.payload == "" {
+			return "", errors.New("connection reset")
+		}
+		return "", nil
+	}
+	if err := device.Do(NewChange().TurnOn()); err != nil {
+		t.Fatalf("Do returned error %v when only fetching the device failed", err)
+	}
+	if !device.IsOn() {
+		t.Error("device is off after Do, want the change applied locally")
+	}
+}
+
+func TestDoUpdatesBoost(t *testing.T) {
+	device := parseTestDevice(t, `{"id": "12345678-abcd", "type": "heating", "state": {"mode": "MANUAL", "target": 18}}`)
+
+	if err := device.Do(NewChange().Boost(time.Hour, 22)); err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	if !device.IsBoosting() || device.HeatingMode() != ModeManual || device.TargetTemperature() != 22 {
+		t.Errorf("device has boost %v, mode %q and target %v after boosting, want true, %q and 22",
+			device.IsBoosting(), device.HeatingMode(), device.TargetTemperature(), ModeManual)
+	}
+
+	if err := device.Do(NewChange().CancelBoost()); err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	if device.Mode() != ModeManual || device.TargetTemperature() != 18 || device.BoostRemaining() != 0 {
+		t.Errorf("device has mode %q and target %v after cancelling boost, want %q and 18",
+			device.Mode(), device.TargetTemperature(), ModeManual)
+	}
+}
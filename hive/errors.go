@@ -0,0 +1,81 @@
+package hive
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// APIError is returned when the server responds to a request with a status
+// other than OK.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// ErrorText is the error message sent by the server, or an empty string if
+	// there is none.
+	ErrorText string
+
+	// Method is the HTTP method of the request.
+	Method string
+
+	// URL is the URL the request was sent to.
+	URL string
+
+	// Body is the raw body of the response.
+	Body []byte
+
+	// RetryAfter is how long the server asked the client to wait before sending
+	// the request again, or zero if it didn't.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.ErrorText == "" {
+		return fmt.Sprintf("%s %s: got HTTP status %s", e.Method, e.URL, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%s %s: got HTTP status %s, error text %q", e.Method, e.URL, http.StatusText(e.StatusCode), e.ErrorText)
+}
+
+// IsUnauthorized returns true if the error is an APIError caused by the server
+// not accepting the token, usually because it expired.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsForbidden returns true if the error is an APIError caused by the server
+// denying access to the requested resource.
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+// IsNotFound returns true if the error is an APIError caused by the requested
+// resource, such as a device, not existing.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsRateLimited returns true if the error is an APIError caused by sending too
+// many requests.
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// IsServerError returns true if the error is an APIError caused by a failure on
+// the server side.
+func IsServerError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode >= 500
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// isAuthError returns true if the given error means the server rejected the
+// token used for the request.
+func isAuthError(err error) bool {
+	return IsUnauthorized(err) || IsForbidden(err)
+}
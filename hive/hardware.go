@@ -0,0 +1,118 @@
+package hive
+
+import (
+	"strings"
+	"time"
+)
+
+// PowerSource describes how a device is powered.
+type PowerSource int
+
+const (
+	// PowerUnknown means the device didn't report its power source.
+	PowerUnknown PowerSource = iota
+
+	// PowerMains means the device is connected to mains electricity.
+	PowerMains
+
+	// PowerBattery means the device runs on batteries.
+	PowerBattery
+)
+
+func (p PowerSource) String() string {
+	switch p {
+	case PowerMains:
+		return "mains"
+	case PowerBattery:
+		return "battery"
+	default:
+		return "unknown"
+	}
+}
+
+// HardwareInfo contains details about the hardware of a device. Fields the
+// device didn't report are left empty.
+type HardwareInfo struct {
+	// Manufacturer is the name of the company that made the device.
+	Manufacturer string
+
+	// Model is the model name of the device.
+	Model string
+
+	// Firmware is the version of the firmware running on the device.
+	Firmware string
+
+	// Power is how the device is powered.
+	Power PowerSource
+
+	// Signal is the strength of the device's wireless signal, as a percentage
+	// between 0 and 100, or -1 if unknown.
+	Signal int
+
+	// Connection is the kind of connection the device uses.
+	Connection string
+
+	// IPAddress is the IP address of the device, if it's connected to the
+	// network directly.
+	IPAddress string
+
+	// Migrating is true while the device is being migrated to a new platform.
+	Migrating bool
+
+	// PMZ is the power management zone of the device.
+	PMZ string
+
+	// Uptime is how long the device has been running since it last restarted.
+	Uptime time.Duration
+}
+
+// Hardware returns details about the hardware of this device.
+func (d *Device) Hardware() HardwareInfo {
+	props := d.snapshot().Props
+	info := HardwareInfo{
+		Manufacturer: stringValue(props.Manufacturer),
+		Model:        stringValue(props.Model),
+		Firmware:     stringValue(props.Version),
+		Power:        parsePowerSource(stringValue(props.Power)),
+		Signal:       -1,
+		Connection:   stringValue(props.Connection),
+		IPAddress:    stringValue(props.IPAddress),
+		Migrating:    props.Migrating != nil && *props.Migrating,
+		PMZ:          stringValue(props.PMZ),
+	}
+	if props.Signal != nil {
+		info.Signal = clamp(*props.Signal, 0, 100)
+	}
+	if props.Uptime != nil {
+		info.Uptime = time.Duration(*props.Uptime) * time.Second
+	}
+	return info
+}
+
+func parsePowerSource(power string) PowerSource {
+	switch strings.ToLower(power) {
+	case "mains":
+		return PowerMains
+	case "battery":
+		return PowerBattery
+	default:
+		return PowerUnknown
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func clamp(value int, min int, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,37 @@
+package hive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHardware(t *testing.T) {
+	device := parseTestDevice(t, `{
+		"id": "12345678-abcd",
+		"type": "motionsensor",
+		"props": {
+			"manufacturer": "Computime",
+			"model": "MOT003",
+			"version": "04087000",
+			"power": "battery",
+			"signal": 120,
+			"uptime": 3600
+		}
+	}`)
+
+	want := HardwareInfo{
+		Manufacturer: "Computime",
+		Model:        "MOT003",
+		Firmware:     "04087000",
+		Power:        PowerBattery,
+		Signal:       100,
+		Uptime:       time.Hour,
+	}
+	if info := device.Hardware(); info != want {
+		t.Errorf("Hardware returned %+v, want %+v", info, want)
+	}
+
+	if info := parseTestDevice(t, `{"id": "1"}`).Hardware(); info.Power != PowerUnknown || info.Signal != -1 {
+		t.Errorf("Hardware returned power %v and signal %d for empty device, want unknown and -1", info.Power, info.Signal)
+	}
+}
@@ -2,8 +2,8 @@ package hive
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -12,6 +12,8 @@ import (
 const (
 	requestOrigin  = "https://my.hivehome.com"
 	headerKeyToken = "Authorization"
+
+	headerKeyRetryAfter = "Retry-After"
 )
 
 var (
@@ -23,26 +25,40 @@ var (
 )
 
 type httpClient struct {
-	client http.Client
+	// client is used to send the requests. If nil, http.DefaultClient is used.
+	client *http.Client
+
+	// headers are set on every request after the custom headers.
+	headers http.Header
+
+	retry  RetryPolicy
+	logger Logger
 }
 
-func (c *httpClient) prepareRequest(method string, url string, jsonStr []byte) (*http.Request, error) {
+func (c *httpClient) prepareRequest(ctx context.Context, method string, url string, jsonStr []byte) (*http.Request, error) {
 	body := io.Reader(nil)
 	if jsonStr != nil {
 		body = bytes.NewBuffer(jsonStr)
 	}
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
 	for _, header := range customHeaders {
 		req.Header.Set(header[0], header[1])
 	}
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
 	return req, nil
 }
 
 func (c *httpClient) sendRequest(req *http.Request) ([]byte, error) {
-	resp, err := c.client.Do(req)
+	client := c.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -52,40 +68,70 @@ func (c *httpClient) sendRequest(req *http.Request) ([]byte, error) {
 		return nil, err
 	}
 
-	return checkError(resp.StatusCode, body)
+	body, err = checkError(req, resp.StatusCode, body)
+	if apiErr, ok := err.(*APIError); ok {
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get(headerKeyRetryAfter))
+	}
+	return body, err
+}
+
+// do sends the request, retrying it according to the retry policy.
+func (c *httpClient) do(ctx context.Context, method string, url string, jsonStr []byte, token string) ([]byte, error) {
+	policy := retryPolicy(ctx, method, c.retry)
+	for retry := 1; ; retry++ {
+		req, err := c.prepareRequest(ctx, method, url, jsonStr)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			req.Header.Set(headerKeyToken, token)
+		}
+
+		body, err := c.sendRequest(req)
+		if err == nil {
+			return body, nil
+		}
+		delay, ok := policy.delay(retry, err)
+		if !ok {
+			logf(c.logger, "%s %s failed: %v", method, url, err)
+			return body, err
+		}
+		logf(c.logger, "%s %s failed, retrying in %v: %v", method, url, delay, err)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
 }
 
-func checkError(status int, body []byte) ([]byte, error) {
+func checkError(req *http.Request, status int, body []byte) ([]byte, error) {
 	if status == http.StatusOK {
 		return body, nil
 	}
 
+	apiErr := &APIError{
+		StatusCode: status,
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Body:       body,
+	}
 	var errorData jsonError
 	json.Unmarshal(body, &errorData)
-	if errorData.ErrorText == nil {
-		return body, fmt.Errorf("got HTTP status %s", http.StatusText(status))
+	if errorData.ErrorText != nil {
+		apiErr.ErrorText = *errorData.ErrorText
 	}
-	return body, fmt.Errorf("got HTTP status %s, error text %q", http.StatusText(status), *errorData.ErrorText)
+	return body, apiErr
 }
 
-func (c *httpClient) PostJSON(url string, jsonStr []byte, token string) ([]byte, error) {
-	req, err := c.prepareRequest("POST", url, jsonStr)
-	if err != nil {
-		return nil, err
-	}
-	if token != "" {
-		req.Header.Set(headerKeyToken, token)
-	}
-	return c.sendRequest(req)
+func (c *httpClient) PostJSON(ctx context.Context, url string, jsonStr []byte, token string) ([]byte, error) {
+	return c.do(ctx, http.MethodPost, url, jsonStr, token)
 }
 
-func (c *httpClient) Get(url string, token string) ([]byte, error) {
-	req, err := c.prepareRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	if token != "" {
-		req.Header.Set(headerKeyToken, token)
+func (c *httpClient) Get(ctx context.Context, url string, token string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, url, nil, token)
+}
+
+func logf(logger Logger, format string, v ...interface{}) {
+	if logger != nil {
+		logger.Printf(format, v...)
 	}
-	return c.sendRequest(req)
 }
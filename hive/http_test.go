@@ -0,0 +1,38 @@
+package hive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": "NOT_FOUND"}`)
+	}))
+	defer server.Close()
+
+	client := &httpClient{}
+	_, err := client.Get(context.Background(), server.URL+"/nodes/light/1", "abc")
+
+	var apiErr *APIError
+	if !errors.As(fmt.Errorf("wrapped: %w", err), &apiErr) {
+		t.Fatalf("Get returned %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("APIError has status %d, want %d", apiErr.StatusCode, http.StatusNotFound)
+	}
+	if apiErr.ErrorText != "NOT_FOUND" {
+		t.Errorf("APIError has error text %q, want %q", apiErr.ErrorText, "NOT_FOUND")
+	}
+	if apiErr.Method != "GET" || apiErr.URL != server.URL+"/nodes/light/1" {
+		t.Errorf("APIError has request %s %s, want GET %s/nodes/light/1", apiErr.Method, apiErr.URL, server.URL)
+	}
+	if !IsNotFound(err) || IsUnauthorized(err) || IsRateLimited(err) || IsServerError(err) {
+		t.Errorf("APIError with status %d matched the wrong helpers", apiErr.StatusCode)
+	}
+}
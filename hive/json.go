@@ -12,6 +12,9 @@ type jsonError struct {
 type jsonTimestamp time.Time
 
 func (j *jsonTimestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
 	var timestamp int64
 	if err := json.Unmarshal(data, &timestamp); err != nil {
 		return err
@@ -90,7 +93,19 @@ type jsonProps struct {
 	Migrating    *bool       `json:"migrating"`
 	PMZ          *string     `json:"pmz"`
 	Uptime       *int        `json:"uptime"`
+	Battery      *int        `json:"battery"`
 	Motion       *jsonMotion `json:"motion"`
+
+	// Heating
+	Temperature *float64      `json:"temperature"`
+	Previous    *jsonPrevious `json:"previous"`
+
+	// Plug
+	PowerConsumption *float64 `json:"powerConsumption"`
+
+	// Contact sensor
+	Status  *string      `json:"status"`
+	Contact *jsonContact `json:"contact"`
 }
 
 type jsonState struct {
@@ -107,6 +122,11 @@ type jsonState struct {
 	Hue               *int    `json:"hue,omitempty"`
 	Saturation        *int    `json:"saturation,omitempty"`
 	Value             *int    `json:"value,omitempty"`
+
+	// Heating
+	Target          *float64 `json:"target,omitempty"`
+	Boost           *int     `json:"boost,omitempty"`
+	FrostProtection *float64 `json:"frostProtection,omitempty"`
 }
 
 type jsonMotion struct {
@@ -115,6 +135,17 @@ type jsonMotion struct {
 	End    jsonTimestamp `json:"end"`
 }
 
+// jsonPrevious contains the state of a device before a boost was started.
+type jsonPrevious struct {
+	Mode   *string  `json:"mode"`
+	Target *float64 `json:"target"`
+}
+
+type jsonContact struct {
+	Opened jsonTimestamp `json:"opened"`
+	Closed jsonTimestamp `json:"closed"`
+}
+
 type jsonSchedule struct {
 	Monday    []jsonScheduleEntry `json:"monday"`
 	Tuesday   []jsonScheduleEntry `json:"tuesday"`
@@ -0,0 +1,262 @@
+package hive
+
+import (
+	"sync"
+	"time"
+)
+
+// maxMotionIntervals is the number of past motion intervals kept per sensor.
+const maxMotionIntervals = 50
+
+// MotionInterval is a period during which a motion sensor detected motion.
+type MotionInterval struct {
+	// Start is when the motion started.
+	Start time.Time
+
+	// End is when the motion ended, or the zero time if it's still ongoing.
+	End time.Time
+
+	// Missed is true if the motion started and ended between two updates, so it
+	// was only noticed from the timestamps reported by the sensor. Any other
+	// motion that happened between the same updates is merged into it.
+	Missed bool
+}
+
+// Ongoing returns true if the motion hasn't ended yet.
+func (i MotionInterval) Ongoing() bool {
+	return i.End.IsZero()
+}
+
+// OccupancyEventType describes how the occupancy of the area covered by a
+// motion sensor changed.
+type OccupancyEventType int
+
+const (
+	// OccupancyEntered means motion was detected in an area that was vacant.
+	OccupancyEntered OccupancyEventType = iota
+
+	// OccupancyLeft means no motion was detected in an area for the vacancy
+	// timeout.
+	OccupancyLeft
+)
+
+func (t OccupancyEventType) String() string {
+	switch t {
+	case OccupancyEntered:
+		return "entered"
+	case OccupancyLeft:
+		return "left"
+	default:
+		return "unknown"
+	}
+}
+
+// OccupancyEvent describes a change in the occupancy of the area covered by a
+// motion sensor.
+type OccupancyEvent struct {
+	// Type is how the occupancy changed.
+	Type OccupancyEventType
+
+	// Device is the motion sensor.
+	Device *Device
+
+	// Time is when the change happened: the start of the motion for entering,
+	// and the end of the vacancy timeout for leaving.
+	Time time.Time
+}
+
+// MotionTracker reconstructs the motion detected by motion sensors from their
+// successive states and tracks whether the areas they cover are occupied. An
+// area is occupied while there is motion, and for the vacancy timeout after
+// the motion ends.
+//
+// The tracker can be fed from the events of Client.Watch, together with a timer
+// so areas become vacant even when nothing changes:
+//
+//	events := client.Watch(ctx, 10*time.Second)
+//	ticker := time.NewTicker(time.Minute)
+//	for {
+//		var occupancy []hive.OccupancyEvent
+//		select {
+//		case event, ok := <-events:
+//			if !ok {
+//				return
+//			}
+//			if event.Device != nil {
+//				occupancy = tracker.Update(event.Device)
+//			}
+//		case <-ticker.C:
+//			occupancy = tracker.Update()
+//		}
+//		// Handle occupancy...
+//	}
+//
+// A MotionTracker is safe for concurrent use by multiple goroutines.
+type MotionTracker struct {
+	timeout time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	sensors map[string]*motionState
+}
+
+type motionState struct {
+	device    *Device
+	start     time.Time
+	end       time.Time
+	active    bool
+	occupied  bool
+	intervals []MotionInterval
+}
+
+// NewMotionTracker returns a tracker that considers an area vacant once no
+// motion was detected in it for the given timeout.
+func NewMotionTracker(vacancyTimeout time.Duration) *MotionTracker {
+	return &MotionTracker{
+		timeout: vacancyTimeout,
+		now:     time.Now,
+		sensors: make(map[string]*motionState),
+	}
+}
+
+// Update records the current state of the given devices, ignoring the ones that
+// aren't motion sensors, and returns the occupancy changes it caused. Sensors
+// that are occupied when seen for the first time cause an OccupancyEntered
+// event.
+//
+// Sensors not passed to Update keep their last state, but areas only become
+// vacant when Update is called, so it should also be called periodically, with
+// or without devices.
+func (t *MotionTracker) Update(devices ...*Device) []OccupancyEvent {
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var events []OccupancyEvent
+	seen := make(map[string]bool, len(devices))
+	for _, device := range devices {
+		if !device.IsMotionSensor() {
+			continue
+		}
+		seen[device.ID()] = true
+		state := t.sensors[device.ID()]
+		if state == nil {
+			state = &motionState{}
+			t.sensors[device.ID()] = state
+		}
+		state.device = device
+		state.update(device.snapshot().Props.Motion)
+		if event, ok := state.checkOccupancy(now, t.timeout); ok {
+			events = append(events, event)
+		}
+	}
+	for id, state := range t.sensors {
+		if seen[id] {
+			continue
+		}
+		if event, ok := state.checkOccupancy(now, t.timeout); ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Occupied returns true if the area covered by the motion sensor with the
+// given ID is currently occupied.
+func (t *MotionTracker) Occupied(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.sensors[id]
+	return state != nil && state.occupied
+}
+
+// Intervals returns the most recent motion detected by the sensor with the
+// given ID, oldest first.
+func (t *MotionTracker) Intervals(id string) []MotionInterval {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.sensors[id]
+	if state == nil {
+		return nil
+	}
+	return append([]MotionInterval(nil), state.intervals...)
+}
+
+// update records the motion reported by the sensor.
+func (s *motionState) update(motion *jsonMotion) {
+	if motion == nil {
+		return
+	}
+	start, end := time.Time(motion.Start), time.Time(motion.End)
+	firstSeen := s.start.IsZero() && s.end.IsZero() && len(s.intervals) == 0
+
+	switch {
+	case start.After(s.start) && !firstSeen:
+		// Motion started again since the last update, so any ongoing motion
+		// ended in between.
+		if s.active {
+			s.closeInterval(start)
+		}
+		if motion.Status {
+			s.addInterval(MotionInterval{Start: start})
+		} else {
+			s.addInterval(MotionInterval{Start: start, End: endAfter(end, start), Missed: true})
+		}
+	case firstSeen && !start.IsZero():
+		if motion.Status {
+			s.addInterval(MotionInterval{Start: start})
+		} else {
+			s.addInterval(MotionInterval{Start: start, End: endAfter(end, start)})
+		}
+	case s.active && !motion.Status:
+		s.closeInterval(endAfter(end, s.start))
+	}
+
+	s.start, s.end, s.active = start, end, motion.Status
+}
+
+func (s *motionState) addInterval(interval MotionInterval) {
+	s.intervals = append(s.intervals, interval)
+	if len(s.intervals) > maxMotionIntervals {
+		s.intervals = s.intervals[len(s.intervals)-maxMotionIntervals:]
+	}
+}
+
+// closeInterval ends the ongoing motion at the given time.
+func (s *motionState) closeInterval(end time.Time) {
+	if len(s.intervals) == 0 {
+		return
+	}
+	last := &s.intervals[len(s.intervals)-1]
+	if last.Ongoing() {
+		last.End = endAfter(end, last.Start)
+	}
+}
+
+// checkOccupancy updates whether the area is occupied at the given time,
+// returning the event for the change if it changed.
+func (s *motionState) checkOccupancy(now time.Time, timeout time.Duration) (OccupancyEvent, bool) {
+	occupied := false
+	var last MotionInterval
+	if len(s.intervals) > 0 {
+		last = s.intervals[len(s.intervals)-1]
+		occupied = last.Ongoing() || now.Sub(last.End) < timeout
+	}
+	if occupied == s.occupied {
+		return OccupancyEvent{}, false
+	}
+	s.occupied = occupied
+	if occupied {
+		return OccupancyEvent{Type: OccupancyEntered, Device: s.device, Time: last.Start}, true
+	}
+	return OccupancyEvent{Type: OccupancyLeft, Device: s.device, Time: last.End.Add(timeout)}, true
+}
+
+// endAfter returns the given end time, or start if the end isn't after it,
+// since sensors don't always report when motion ended.
+func endAfter(end time.Time, start time.Time) time.Time {
+	if end.Before(start) {
+		return start
+	}
+	return end
+}
@@ -0,0 +1,116 @@
+package hive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func motionSensor(t *testing.T, status bool, start, end time.Time) *Device {
+	t.Helper()
+	return parseTestDevice(t, fmt.Sprintf(
+		`{"id": "hall", "type": "motionsensor", "props": {"motion": {"status": %t, "start": %d, "end": %d}}}`,
+		status, start.UnixNano()/int64(time.Millisecond), end.UnixNano()/int64(time.Millisecond)))
+}
+
+func TestMotionTracker(t *testing.T) {
+	base := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	now := base
+	tracker := NewMotionTracker(5 * time.Minute)
+	tracker.now = func() time.Time { return now }
+
+	expectEvents := func(events []OccupancyEvent, want ...OccupancyEventType) {
+		t.Helper()
+		if len(events) != len(want) {
+			t.Fatalf("got events %v, want %v", events, want)
+		}
+		for i := range want {
+			if events[i].Type != want[i] {
+				t.Fatalf("got events %v, want %v", events, want)
+			}
+		}
+	}
+
+	// Old motion, long before the tracker started.
+	expectEvents(tracker.Update(motionSensor(t, false, base.Add(-time.Hour), base.Add(-59*time.Minute))))
+	if tracker.Occupied("hall") {
+		t.Error("area occupied after old motion")
+	}
+
+	// Motion starts and is seen while ongoing.
+	now = base.Add(time.Minute)
+	expectEvents(tracker.Update(motionSensor(t, true, base.Add(30*time.Second), base.Add(-59*time.Minute))), OccupancyEntered)
+
+	// Motion ends, but the area stays occupied until the timeout.
+	now = base.Add(2 * time.Minute)
+	expectEvents(tracker.Update(motionSensor(t, false, base.Add(30*time.Second), base.Add(90*time.Second))))
+	if !tracker.Occupied("hall") {
+		t.Error("area vacant before the timeout")
+	}
+
+	now = base.Add(7 * time.Minute)
+	events := tracker.Update(motionSensor(t, false, base.Add(30*time.Second), base.Add(90*time.Second)))
+	expectEvents(events, OccupancyLeft)
+	if want := base.Add(90*time.Second + 5*time.Minute); !events[0].Time.Equal(want) {
+		t.Errorf("left at %v, want %v", events[0].Time, want)
+	}
+
+	// A short burst happens entirely between two updates.
+	now = base.Add(10 * time.Minute)
+	expectEvents(tracker.Update(motionSensor(t, false, base.Add(8*time.Minute), base.Add(9*time.Minute))), OccupancyEntered)
+
+	intervals := tracker.Intervals("hall")
+	if len(intervals) != 3 {
+		t.Fatalf("got intervals %v, want 3", intervals)
+	}
+	if intervals[1].Missed || !intervals[1].End.Equal(base.Add(90*time.Second)) {
+		t.Errorf("second interval is %+v, want observed motion ending at %v", intervals[1], base.Add(90*time.Second))
+	}
+	if !intervals[2].Missed || !intervals[2].Start.Equal(base.Add(8*time.Minute)) {
+		t.Errorf("third interval is %+v, want missed motion starting at %v", intervals[2], base.Add(8*time.Minute))
+	}
+
+	// The sensor disappears, but the area still becomes vacant.
+	now = base.Add(20 * time.Minute)
+	expectEvents(tracker.Update(), OccupancyLeft)
+}
+
+func TestMotionTrackerFromWatch(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	results := make(chan string, 2)
+	results <- fmt.Sprintf(`[{"id": "hall", "type": "motionsensor", "props": {"motion": {"status": false, "start": %d, "end": %d}}}]`,
+		base.UnixNano()/int64(time.Millisecond), base.Add(time.Minute).UnixNano()/int64(time.Millisecond))
+	burst := time.Now().Add(-time.Minute)
+	results <- fmt.Sprintf(`[{"id": "hall", "type": "motionsensor", "props": {"motion": {"status": false, "start": %d, "end": %d}}}]`,
+		burst.UnixNano()/int64(time.Millisecond), burst.Add(time.Second).UnixNano()/int64(time.Millisecond))
+	mock := &mockEndpoint{handle: func(url string, token string) (string, error) {
+		select {
+		case result := <-results:
+			return result, nil
+		default:
+			return "", errors.New("no more results")
+		}
+	}}
+	client := &Client{client: mock}
+	tracker := NewMotionTracker(5 * time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var occupancy []OccupancyEvent
+	for event := range client.Watch(ctx, time.Millisecond) {
+		if event.Type == EventError {
+			break
+		}
+		occupancy = append(occupancy, tracker.Update(event.Device)...)
+	}
+
+	if len(occupancy) != 1 || occupancy[0].Type != OccupancyEntered {
+		t.Errorf("got occupancy events %v, want entered", occupancy)
+	}
+	intervals := tracker.Intervals("hall")
+	if len(intervals) != 2 || !intervals[1].Missed {
+		t.Errorf("got intervals %+v, want the old motion and the missed burst", intervals)
+	}
+}
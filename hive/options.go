@@ -0,0 +1,123 @@
+package hive
+
+import "net/http"
+
+// Option configures a client created by NewClient.
+type Option func(*options)
+
+type options struct {
+	httpClient  *http.Client
+	userAgent   string
+	origin      string
+	endpointURL string
+	headers     http.Header
+	logger      Logger
+	retry       RetryPolicy
+
+	refreshAfterDo bool
+	pageSize       int
+}
+
+// Logger is used by the client to log retries, new logins and failed requests.
+// A *log.Logger can be used as a Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithHTTPClient makes the client send all requests using the given HTTP
+// client, which can be used to set timeouts, proxies or a custom transport.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithOrigin sets the Origin header sent with every request, which defaults to
+// the origin of the Hive web app.
+func WithOrigin(origin string) Option {
+	return func(o *options) {
+		o.origin = origin
+	}
+}
+
+// WithEndpointURL sets the URL of the API endpoint, which is useful when using a
+// saved token instead of calling Login.
+func WithEndpointURL(url string) Option {
+	return func(o *options) {
+		o.endpointURL = url
+	}
+}
+
+// WithBaseHeaders adds the given headers to every request. They take precedence
+// over the headers the client sets by default, including the ones set by
+// WithUserAgent and WithOrigin.
+func WithBaseHeaders(headers http.Header) Option {
+	return func(o *options) {
+		if o.headers == nil {
+			o.headers = make(http.Header)
+		}
+		for key, values := range headers {
+			o.headers[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+	}
+}
+
+// WithLogger makes the client log retries, new logins and failed requests to
+// the given logger.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithRetryPolicy makes the client retry requests that fail due to transient
+// errors according to the given policy. By default, requests aren't retried.
+// The policy can be overridden for a single call using ContextWithRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// WithRefreshAfterDo makes Device.Do fetch the device from the server after
+// applying a change, so its state is the one reported by the server. By
+// default, the change is applied to the state the client already has, which
+// saves a request. That's also done if fetching the device fails.
+func WithRefreshAfterDo() Option {
+	return func(o *options) {
+		o.refreshAfterDo = true
+	}
+}
+
+// WithPageSize makes the client load the list of products in pages, for
+// servers that return at most the given number of products per request. The
+// next page is requested after the last product of the previous one, until a
+// page with fewer products is returned. By default, a single request is made.
+func WithPageSize(size int) Option {
+	return func(o *options) {
+		o.pageSize = size
+	}
+}
+
+// requestHeaders returns the headers the client sends in addition to the
+// default ones.
+func (o *options) requestHeaders() http.Header {
+	headers := make(http.Header)
+	if o.origin != "" {
+		headers.Set("Origin", o.origin)
+	}
+	if o.userAgent != "" {
+		headers.Set("User-Agent", o.userAgent)
+	}
+	for key, values := range o.headers {
+		headers[key] = values
+	}
+	return headers
+}
@@ -0,0 +1,180 @@
+package hive
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy describes how requests that fail due to transient errors are
+// retried. Only GET requests and device state changes are retried, since
+// sending them multiple times has the same effect as sending them once.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent, including
+	// the first one. Values lower than 2 disable retries.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It doubles after every
+	// failed retry.
+	BaseDelay time.Duration
+
+	// MaxDelay is the longest delay between two attempts. If the server asks the
+	// client to wait for longer than this using the Retry-After header, the
+	// request is not retried. Zero means no limit.
+	MaxDelay time.Duration
+
+	// Jitter is the fraction of every delay that's randomized, between 0 and 1.
+	// For example, a jitter of 0.2 makes a 1 second delay last between 0.8 and 1
+	// second.
+	Jitter float64
+
+	// RetryableStatus contains the HTTP status codes of responses that are
+	// retried.
+	RetryableStatus []int
+
+	// RetryableError, if set, decides whether a request that failed without
+	// getting a response is retried. If nil, connection resets and refusals,
+	// unexpected EOFs and timeouts are retried.
+	RetryableError func(err error) bool
+}
+
+// DefaultRetryPolicy retries requests up to 3 times when the connection fails
+// or the server is temporarily unavailable.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	Jitter:      0.5,
+	RetryableStatus: []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+type retryPolicyKey struct{}
+
+type idempotentKey struct{}
+
+// ContextWithRetryPolicy returns a copy of the context that makes requests made
+// with it use the given retry policy instead of the one set on the client.
+func ContextWithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// withIdempotent returns a copy of the context that marks POST requests made
+// with it as safe to retry.
+func withIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(ctx context.Context) bool {
+	idempotent, _ := ctx.Value(idempotentKey{}).(bool)
+	return idempotent
+}
+
+// retryPolicy returns the policy to use for the given request, which is the one
+// in the context if present or the default one otherwise.
+func retryPolicy(ctx context.Context, method string, policy RetryPolicy) RetryPolicy {
+	if p, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+		policy = p
+	}
+	if method != http.MethodGet && !isIdempotent(ctx) {
+		policy.MaxAttempts = 1
+	}
+	return policy
+}
+
+// delay returns how long to wait before the given retry, with the first retry
+// being 1. It returns false if the request shouldn't be retried.
+func (p *RetryPolicy) delay(retry int, err error) (time.Duration, bool) {
+	if retry >= p.MaxAttempts || !p.retryable(err) {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if p.MaxDelay > 0 && apiErr.RetryAfter > p.MaxDelay {
+			return 0, false
+		}
+		return apiErr.RetryAfter, true
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(retry-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay -= delay * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(delay), true
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		for _, status := range p.RetryableStatus {
+			if apiErr.StatusCode == status {
+				return true
+			}
+		}
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if p.RetryableError != nil {
+		return p.RetryableError(err)
+	}
+	return isTransientError(err)
+}
+
+func isTransientError(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parseRetryAfter parses the value of the Retry-After header, which is either a
+// number of seconds or a date. It returns 0 if the value is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// sleep waits for the given duration, returning early with an error if the
+// context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,127 @@
+package hive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	BaseDelay:       time.Millisecond,
+	RetryableStatus: []int{http.StatusServiceUnavailable},
+}
+
+func newFlakyServer(failures int32, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+}
+
+func TestRetry(t *testing.T) {
+	var requests int32
+	server := newFlakyServer(2, &requests)
+	defer server.Close()
+	client := &httpClient{retry: testRetryPolicy}
+
+	if _, err := client.Get(context.Background(), server.URL, ""); err != nil {
+		t.Errorf("Get returned error: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("server got %d requests, want 3", requests)
+	}
+
+	requests = 0
+	if _, err := client.PostJSON(context.Background(), server.URL, []byte("{}"), ""); !IsServerError(err) {
+		t.Errorf("PostJSON returned %v, want server error without retrying", err)
+	}
+	if requests != 1 {
+		t.Errorf("server got %d requests for non-idempotent POST, want 1", requests)
+	}
+
+	requests = 0
+	if _, err := client.PostJSON(withIdempotent(context.Background()), server.URL, []byte("{}"), ""); err != nil {
+		t.Errorf("PostJSON returned error: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("server got %d requests for idempotent POST, want 3", requests)
+	}
+}
+
+func TestRetryContextOverride(t *testing.T) {
+	var requests int32
+	server := newFlakyServer(1, &requests)
+	defer server.Close()
+	client := &httpClient{retry: testRetryPolicy}
+
+	ctx := ContextWithRetryPolicy(context.Background(), RetryPolicy{})
+	if _, err := client.Get(ctx, server.URL, ""); !IsServerError(err) {
+		t.Errorf("Get returned %v, want server error without retrying", err)
+	}
+	if requests != 1 {
+		t.Errorf("server got %d requests, want 1", requests)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:     5,
+		BaseDelay:       time.Second,
+		MaxDelay:        3 * time.Second,
+		RetryableStatus: []int{http.StatusServiceUnavailable},
+	}
+	err := &APIError{StatusCode: http.StatusServiceUnavailable}
+	for retry, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if delay, ok := policy.delay(retry+1, err); !ok || delay != want {
+			t.Errorf("delay for retry %d is %v, %v, want %v, true", retry+1, delay, ok, want)
+		}
+	}
+	if _, ok := policy.delay(5, err); ok {
+		t.Error("request retried after reaching the max number of attempts")
+	}
+
+	err.RetryAfter = 2 * time.Second
+	if delay, ok := policy.delay(1, err); !ok || delay != err.RetryAfter {
+		t.Errorf("delay is %v, %v, want Retry-After value %v, true", delay, ok, err.RetryAfter)
+	}
+	err.RetryAfter = time.Minute
+	if _, ok := policy.delay(1, err); ok {
+		t.Error("request retried although Retry-After is longer than the max delay")
+	}
+	if _, ok := policy.delay(1, &APIError{StatusCode: http.StatusNotFound}); ok {
+		t.Error("request retried for a status that isn't retryable")
+	}
+}
+
+func TestReloginFromDoNotRetried(t *testing.T) {
+	var logins int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			atomic.AddInt32(&logins, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithRetryPolicy(testRetryPolicy), WithEndpointURL(server.URL))
+	client.Token = "expired"
+	client.Credentials = &Credentials{"user", "secret", server.URL + "/login"}
+	client.parseDevices([]jsonEntity{{ID: "12345678-abcd", Type: typeWarmWhiteLight}})
+
+	if err := client.Device("12345678-abcd").Do(NewChange().TurnOn()); !IsServerError(err) {
+		t.Errorf("Do returned %v, want server error from logging in", err)
+	}
+	if logins != 1 {
+		t.Errorf("server got %d login requests, want 1", logins)
+	}
+}
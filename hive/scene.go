@@ -0,0 +1,271 @@
+package hive
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultSceneParallelism is the number of devices changed at the same time
+// when applying a scene that doesn't set MaxParallel.
+const DefaultSceneParallelism = 4
+
+// Selector chooses devices a scene applies to. Predicates of Device can be used
+// as selectors, for example:
+//
+//	scene.Matching((*hive.Device).IsLight, hive.NewChange().TurnOff())
+type Selector func(d *Device) bool
+
+// Scene is a set of changes applied to several devices as a unit by
+// Client.ApplyScene, such as turning off all lights.
+//
+// Like Change, Scene methods are built to be chained. If a device is chosen
+// more than once, only the change added last is applied to it.
+type Scene struct {
+	// Name is the name of the scene, used in error messages.
+	Name string
+
+	// MaxParallel is the maximum number of devices changed at the same time.
+	// If zero, DefaultSceneParallelism is used.
+	MaxParallel int
+
+	// Capture makes ApplyScene record the state of the devices before changing
+	// them, so the scene can be reverted using SceneResult.Revert.
+	Capture bool
+
+	entries []sceneEntry
+}
+
+type sceneEntry struct {
+	id       string
+	selector Selector
+	change   *Change
+}
+
+// NewScene returns an empty scene with the given name.
+func NewScene(name string) *Scene {
+	return &Scene{Name: name}
+}
+
+// Device adds the given change for the device with the given ID.
+func (s *Scene) Device(id string, c *Change) *Scene {
+	s.entries = append(s.entries, sceneEntry{id: id, change: c})
+	return s
+}
+
+// Matching adds the given change for every device chosen by the selector when
+// the scene is applied.
+func (s *Scene) Matching(selector Selector, c *Change) *Scene {
+	s.entries = append(s.entries, sceneEntry{selector: selector, change: c})
+	return s
+}
+
+// DeviceResult is the outcome of applying a scene to a single device.
+type DeviceResult struct {
+	// ID is the ID of the device.
+	ID string
+
+	// Device is the device, or nil if no device with the ID was found.
+	Device *Device
+
+	// Err is the error returned when applying the change, or nil if it was
+	// applied successfully.
+	Err error
+}
+
+// SceneResult is the outcome of applying a scene.
+type SceneResult struct {
+	// Devices contains the result for every device the scene applies to,
+	// ordered the same way as in the Hive app, with devices that weren't found
+	// last.
+	Devices []DeviceResult
+
+	// Revert is a scene that restores the devices that were changed to their
+	// previous state. It's only set if the scene had Capture set. Devices with no
+	// known previous value for any of the changed fields are left out.
+	Revert *Scene
+}
+
+// SceneError is returned by ApplyScene when the scene couldn't be applied to
+// some of the devices.
+type SceneError struct {
+	// Scene is the name of the scene.
+	Scene string
+
+	// Failed contains the results of the devices that weren't changed.
+	Failed []DeviceResult
+}
+
+func (e *SceneError) Error() string {
+	messages := make([]string, len(e.Failed))
+	for i, result := range e.Failed {
+		messages[i] = fmt.Sprintf("%s: %v", result.ID, result.Err)
+	}
+	return fmt.Sprintf("applying scene %q failed for %d devices: %s",
+		e.Scene, len(e.Failed), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the errors of the devices that weren't changed, so errors.Is
+// and errors.As can be used on them.
+func (e *SceneError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, result := range e.Failed {
+		errs[i] = result.Err
+	}
+	return errs
+}
+
+// ApplyScene applies the changes of the given scene to its devices, changing
+// several devices at the same time. It always returns the result for every
+// device, and a *SceneError if any of them couldn't be changed. Devices with
+// IDs the client doesn't know about fail with ErrDeviceNotFound.
+func (c *Client) ApplyScene(ctx context.Context, scene *Scene) (*SceneResult, error) {
+	targets := c.resolveScene(scene)
+
+	parallel := scene.MaxParallel
+	if parallel <= 0 {
+		parallel = DefaultSceneParallelism
+	}
+	results := make([]DeviceResult, len(targets))
+	reverts := make([]*Change, len(targets))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		results[i] = DeviceResult{ID: target.id, Device: target.device}
+		if target.device == nil {
+			results[i].Err = fmt.Errorf("%w: %s", ErrDeviceNotFound, target.id)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, target sceneTarget) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+
+			previous := target.device.snapshot()
+			if err := target.device.DoContext(ctx, target.change); err != nil {
+				results[i].Err = err
+				return
+			}
+			if scene.Capture {
+				reverts[i] = revertChange(previous, target.change.stateFor(previous))
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	result := &SceneResult{Devices: results}
+	if scene.Capture {
+		result.Revert = &Scene{Name: scene.Name + " (revert)", MaxParallel: scene.MaxParallel}
+		for i, change := range reverts {
+			if change != nil {
+				result.Revert.Device(results[i].ID, change)
+			}
+		}
+	}
+
+	var failed []DeviceResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 {
+		return result, &SceneError{Scene: scene.Name, Failed: failed}
+	}
+	return result, nil
+}
+
+type sceneTarget struct {
+	id     string
+	device *Device
+	change *Change
+}
+
+// resolveScene returns the devices the scene applies to, with the change for
+// each of them.
+func (c *Client) resolveScene(scene *Scene) []sceneTarget {
+	devices := c.Devices()
+	sortDevices(devices)
+
+	changes := make(map[string]*Change)
+	var missing []string
+	for _, entry := range scene.entries {
+		if entry.selector == nil {
+			if c.Device(entry.id) == nil {
+				if _, ok := changes[entry.id]; !ok {
+					missing = append(missing, entry.id)
+				}
+			}
+			changes[entry.id] = entry.change
+			continue
+		}
+		for _, device := range devices {
+			if entry.selector(device) {
+				changes[device.ID()] = entry.change
+			}
+		}
+	}
+
+	var targets []sceneTarget
+	for _, device := range devices {
+		if change, ok := changes[device.ID()]; ok {
+			targets = append(targets, sceneTarget{device.ID(), device, change})
+		}
+	}
+	for _, id := range missing {
+		targets = append(targets, sceneTarget{id: id, change: changes[id]})
+	}
+	return targets
+}
+
+// revertChange returns a change that restores the fields set by the given
+// state to the values they had in the given previous state of a device, or nil
+// if none of them had a value, since there's nothing to restore.
+func revertChange(previous *jsonEntity, applied *jsonState) *Change {
+	old := &previous.State
+	var state jsonState
+	if applied.Name != nil {
+		state.Name = copyString(old.Name)
+	}
+	if applied.Status != nil {
+		state.Status = copyString(old.Status)
+	}
+	if applied.Brightness != nil {
+		state.Brightness = copyInt(old.Brightness)
+	}
+	if applied.Schedule != nil {
+		state.Schedule = old.Schedule
+	}
+	if applied.ColourMode != nil || applied.Hue != nil || applied.ColourTemperature != nil {
+		state.ColourMode = copyString(old.ColourMode)
+		if old.ColourMode != nil && *old.ColourMode == colourModeCOLOUR {
+			state.Hue = copyInt(old.Hue)
+			state.Saturation = copyInt(old.Saturation)
+			state.Value = copyInt(old.Value)
+		} else {
+			state.ColourTemperature = copyInt(old.ColourTemperature)
+		}
+	}
+	if applied.Mode != nil || applied.Target != nil || applied.Boost != nil {
+		state.Mode = copyString(old.Mode)
+		if old.Target != nil {
+			target := *old.Target
+			state.Target = &target
+		}
+		if old.Mode != nil && *old.Mode == string(ModeBoost) {
+			state.Boost = copyInt(old.Boost)
+		}
+	}
+	if state == (jsonState{}) {
+		return nil
+	}
+	return &Change{state: state}
+}
@@ -0,0 +1,67 @@
+package hive
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApplyScene(t *testing.T) {
+	var requested []string
+	mock := &mockEndpoint{handle: func(url string, token string) (string, error) {
+		requested = append(requested, url)
+		if strings.HasSuffix(url, "/broken") {
+			return "", errors.New("connection reset")
+		}
+		return "", nil
+	}}
+	client := &Client{client: mock}
+	client.parseDevices([]jsonEntity{
+		{ID: "lamp", Type: typeWarmWhiteLight, State: jsonState{Status: &statusON, Brightness: intPtr(80)}},
+		{ID: "bulb", Type: typeColourLight, State: jsonState{Status: &statusOFF, ColourMode: &colourModeWHITE, ColourTemperature: intPtr(2700)}},
+		{ID: "broken", Type: typeWarmWhiteLight},
+		{ID: "unknown", Type: typeWarmWhiteLight},
+		{ID: "sensor", Type: typeMotionSensor},
+	})
+
+	scene := NewScene("movie night").
+		Matching((*Device).IsLight, NewChange().TurnOff()).
+		Device("bulb", NewChange().TurnOn().Color(ColorBlue)).
+		Device("missing", NewChange().TurnOn())
+	scene.Capture = true
+	scene.MaxParallel = 2
+
+	result, err := client.ApplyScene(context.Background(), scene)
+	var sceneErr *SceneError
+	if !errors.As(err, &sceneErr) || len(sceneErr.Failed) != 2 {
+		t.Fatalf("ApplyScene returned error %v, want SceneError with 2 failures", err)
+	}
+	if !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("ApplyScene returned error %v, want it to wrap %v", err, ErrDeviceNotFound)
+	}
+	if len(result.Devices) != 5 || len(requested) != 4 {
+		t.Errorf("ApplyScene returned %d results and sent %d requests, want 5 and 4", len(result.Devices), len(requested))
+	}
+	if client.Device("lamp").IsOn() || !client.Device("bulb").IsOn() || client.Device("bulb").Color() != ColorBlue {
+		t.Error("devices don't have the state set by the scene")
+	}
+
+	if result.Revert == nil || len(result.Revert.entries) != 2 {
+		t.Fatalf("ApplyScene returned revert scene %+v, want one for lamp and bulb only", result.Revert)
+	}
+	if _, err := client.ApplyScene(context.Background(), result.Revert); err != nil {
+		t.Fatalf("reverting scene returned error: %v", err)
+	}
+	lamp, bulb := client.Device("lamp"), client.Device("bulb")
+	if !lamp.IsOn() || lamp.Brightness() != 80 {
+		t.Errorf("lamp has status %v and brightness %d after revert, want on and 80", lamp.IsOn(), lamp.Brightness())
+	}
+	if bulb.IsOn() || bulb.ColorTemperature() != 2700 || *bulb.snapshot().State.ColourMode != colourModeWHITE {
+		t.Errorf("bulb is %v after revert, want off in white mode", bulb.snapshot().State)
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
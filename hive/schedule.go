@@ -0,0 +1,171 @@
+package hive
+
+import (
+	"fmt"
+	"time"
+)
+
+// Schedule contains the entries of a weekly schedule for each day of the week,
+// ordered by their start time. Days without entries keep the state set by the
+// last entry of the preceding days.
+type Schedule map[time.Weekday][]ScheduleEntry
+
+// ScheduleEntry sets the state of a device at a given time of the day.
+type ScheduleEntry struct {
+	// Start is the time since midnight when the entry takes effect, in whole
+	// minutes.
+	Start time.Duration
+
+	// Value is the state the device is set to.
+	Value ScheduleValue
+}
+
+// ScheduleValue is the state a light is set to by a schedule entry.
+type ScheduleValue struct {
+	// On is true if the light is turned on.
+	On bool
+
+	// Brightness is the brightness of the light, between 0 and 100.
+	Brightness int
+}
+
+// Validate checks that all entries are ordered by their start time, start at a
+// whole minute within the day and have a valid brightness.
+func (s Schedule) Validate() error {
+	for day, entries := range s {
+		if day < time.Sunday || day > time.Saturday {
+			return fmt.Errorf("invalid day of the week %d", day)
+		}
+		for i, entry := range entries {
+			if entry.Start < 0 || entry.Start >= 24*time.Hour {
+				return fmt.Errorf("%s entry %d starts at %v, must be within the day", day, i, entry.Start)
+			}
+			if entry.Start%time.Minute != 0 {
+				return fmt.Errorf("%s entry %d starts at %v, must be a whole minute", day, i, entry.Start)
+			}
+			if i > 0 && entry.Start <= entries[i-1].Start {
+				return fmt.Errorf("%s entry %d starts at %v, must be after the previous one", day, i, entry.Start)
+			}
+			if entry.Value.Brightness < 0 || entry.Value.Brightness > 100 {
+				return fmt.Errorf("%s entry %d has brightness %d, must be between 0 and 100", day, i, entry.Value.Brightness)
+			}
+		}
+	}
+	return nil
+}
+
+// Schedule returns the weekly on and off schedule of this device, or nil if it
+// has none. Heating and hot water schedules set temperatures rather than turning
+// the device on and off, so nil is returned for them, as for any other device
+// whose Capabilities don't include Schedule.
+func (d *Device) Schedule() Schedule {
+	e := d.snapshot()
+	if e.State.Schedule == nil {
+		return nil
+	}
+	if capabilities := d.Capabilities(); capabilities.Known && !capabilities.Schedule {
+		return nil
+	}
+	return scheduleFromJSON(e.State.Schedule)
+}
+
+// days returns pointers to the entries of each day, indexed by time.Weekday.
+func (j *jsonSchedule) days() [7]*[]jsonScheduleEntry {
+	return [7]*[]jsonScheduleEntry{
+		&j.Sunday,
+		&j.Monday,
+		&j.Tuesday,
+		&j.Wednesday,
+		&j.Thursday,
+		&j.Friday,
+		&j.Saturday,
+	}
+}
+
+func scheduleFromJSON(j *jsonSchedule) Schedule {
+	s := make(Schedule)
+	for day, entries := range j.days() {
+		if len(*entries) == 0 {
+			continue
+		}
+		result := make([]ScheduleEntry, len(*entries))
+		for i, entry := range *entries {
+			result[i] = ScheduleEntry{
+				Start: time.Duration(entry.Start) * time.Minute,
+				Value: ScheduleValue{
+					On:         entry.Value.Status == statusON,
+					Brightness: entry.Value.Brightness,
+				},
+			}
+		}
+		s[time.Weekday(day)] = result
+	}
+	return s
+}
+
+func (s Schedule) toJSON() *jsonSchedule {
+	var j jsonSchedule
+	for day, entries := range j.days() {
+		*entries = make([]jsonScheduleEntry, len(s[time.Weekday(day)]))
+		for i, entry := range s[time.Weekday(day)] {
+			status := statusOFF
+			if entry.Value.On {
+				status = statusON
+			}
+			(*entries)[i] = jsonScheduleEntry{
+				Start: int(entry.Start / time.Minute),
+				Value: jsonScheduleDayValue{
+					Brightness: entry.Value.Brightness,
+					Status:     status,
+				},
+			}
+		}
+	}
+	return &j
+}
+
+// At returns the entry that's in effect at the given time, evaluated in the
+// time zone of t, which should usually be the one returned by Client.Location.
+// If the day has no entry starting before t, the last entry of the preceding
+// days is used. It returns false if the schedule has no entries.
+func (s Schedule) At(t time.Time) (ScheduleEntry, bool) {
+	offset := timeOfDay(t)
+	day := t.Weekday()
+	for i := 0; i <= 7; i++ {
+		entries := s[(day+7-time.Weekday(i%7))%7]
+		for j := len(entries) - 1; j >= 0; j-- {
+			if i > 0 || entries[j].Start <= offset {
+				return entries[j], true
+			}
+		}
+	}
+	return ScheduleEntry{}, false
+}
+
+// NextChange returns the first entry starting after the given time, together
+// with the time it starts at. Like At, it's evaluated in the time zone of t. It
+// returns false if the schedule has no entries.
+func (s Schedule) NextChange(t time.Time) (time.Time, ScheduleEntry, bool) {
+	offset := timeOfDay(t)
+	day := t.Weekday()
+	year, month, date := t.Date()
+	for i := 0; i <= 7; i++ {
+		for _, entry := range s[(day+time.Weekday(i))%7] {
+			if i == 0 && entry.Start <= offset {
+				continue
+			}
+			hour := int(entry.Start / time.Hour)
+			minute := int(entry.Start % time.Hour / time.Minute)
+			start := time.Date(year, month, date+i, hour, minute, 0, 0, t.Location())
+			return start, entry, true
+		}
+	}
+	return time.Time{}, ScheduleEntry{}, false
+}
+
+// timeOfDay returns the time elapsed since midnight according to the clock.
+func timeOfDay(t time.Time) time.Duration {
+	hour, min, sec := t.Clock()
+	return time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute +
+		time.Duration(sec)*time.Second + time.Duration(t.Nanosecond())
+}
@@ -0,0 +1,548 @@
+package hive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnrepresentable is returned when importing a schedule containing something
+// that can't be represented as a weekly Hive schedule.
+var ErrUnrepresentable = errors.New("can't be represented as a weekly schedule")
+
+var weekdayNames = [7]string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// scheduleJSON is the JSON representation of a Schedule. The fields are listed
+// in the order days are shown in the Hive app.
+type scheduleJSON struct {
+	Monday    []scheduleEntryJSON `json:"monday,omitempty"`
+	Tuesday   []scheduleEntryJSON `json:"tuesday,omitempty"`
+	Wednesday []scheduleEntryJSON `json:"wednesday,omitempty"`
+	Thursday  []scheduleEntryJSON `json:"thursday,omitempty"`
+	Friday    []scheduleEntryJSON `json:"friday,omitempty"`
+	Saturday  []scheduleEntryJSON `json:"saturday,omitempty"`
+	Sunday    []scheduleEntryJSON `json:"sunday,omitempty"`
+}
+
+type scheduleEntryJSON struct {
+	Start      string `json:"start"`
+	On         bool   `json:"on"`
+	Brightness int    `json:"brightness"`
+}
+
+func (j *scheduleJSON) days() [7]*[]scheduleEntryJSON {
+	return [7]*[]scheduleEntryJSON{
+		&j.Sunday,
+		&j.Monday,
+		&j.Tuesday,
+		&j.Wednesday,
+		&j.Thursday,
+		&j.Friday,
+		&j.Saturday,
+	}
+}
+
+// MarshalJSON encodes the schedule as an object with a key for every day that
+// has entries, from "monday" to "sunday". Each day is a list of entries with
+// the start time formatted as "HH:MM", for example:
+//
+//	{"monday": [{"start": "07:30", "on": true, "brightness": 80}]}
+func (s Schedule) MarshalJSON() ([]byte, error) {
+	var j scheduleJSON
+	for day, entries := range j.days() {
+		for _, entry := range s[time.Weekday(day)] {
+			*entries = append(*entries, scheduleEntryJSON{
+				Start:      formatTimeOfDay(entry.Start),
+				On:         entry.Value.On,
+				Brightness: entry.Value.Brightness,
+			})
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a schedule in the format written by MarshalJSON. It
+// returns an error for unknown keys and for invalid schedules (see
+// Schedule.Validate).
+func (s *Schedule) UnmarshalJSON(data []byte) error {
+	var j scheduleJSON
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&j); err != nil {
+		return err
+	}
+
+	result := make(Schedule)
+	for day, entries := range j.days() {
+		for _, entry := range *entries {
+			start, err := parseTimeOfDay(entry.Start)
+			if err != nil {
+				return fmt.Errorf("%s: %w", weekdayNames[day], err)
+			}
+			result[time.Weekday(day)] = append(result[time.Weekday(day)], ScheduleEntry{
+				Start: start,
+				Value: ScheduleValue{On: entry.On, Brightness: entry.Brightness},
+			})
+		}
+	}
+	if err := result.Validate(); err != nil {
+		return err
+	}
+	*s = result
+	return nil
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid start time %q, must be HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+const (
+	icalDateTime = "20060102T150405"
+	icalProdID   = "-//go-hive//Hive schedule//EN"
+)
+
+var (
+	icalDays = [7]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+	icalDuration = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+	// icalWeek is the week the events of an exported schedule start in, chosen
+	// to start on a Monday.
+	icalWeek = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// MarshalICalendar encodes the schedule as an iCalendar document. Every entry
+// becomes an event repeating weekly on its day of the week, lasting until the
+// next entry of the same day or until midnight. The state an entry sets is
+// stored in the X-HIVE-STATUS and X-HIVE-BRIGHTNESS properties and summarized
+// in the event's summary.
+func (s Schedule) MarshalICalendar() []byte {
+	var buf bytes.Buffer
+	line := func(format string, v ...interface{}) {
+		fmt.Fprintf(&buf, format+"\r\n", v...)
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:%s", icalProdID)
+	line("CALSCALE:GREGORIAN")
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		date := icalWeek.AddDate(0, 0, i-1)
+		entries := s[day]
+		for j, entry := range entries {
+			end := 24 * time.Hour
+			if j+1 < len(entries) {
+				end = entries[j+1].Start
+			}
+			status := statusOFF
+			summary := "Off"
+			if entry.Value.On {
+				status = statusON
+				summary = fmt.Sprintf("On (%d%%)", entry.Value.Brightness)
+			}
+
+			line("BEGIN:VEVENT")
+			line("UID:%s-%s@go-hive", weekdayNames[day], strings.Replace(formatTimeOfDay(entry.Start), ":", "", 1))
+			line("DTSTAMP:%sZ", icalWeek.Format(icalDateTime))
+			line("DTSTART:%s", date.Add(entry.Start).Format(icalDateTime))
+			line("DTEND:%s", date.Add(end).Format(icalDateTime))
+			line("RRULE:FREQ=WEEKLY;BYDAY=%s", icalDays[day])
+			line("SUMMARY:%s", summary)
+			line("X-HIVE-STATUS:%s", status)
+			line("X-HIVE-BRIGHTNESS:%d", entry.Value.Brightness)
+			line("END:VEVENT")
+		}
+	}
+	line("END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// icalProperty is a single content line of an iCalendar document.
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ParseICalendar decodes the events of an iCalendar document into a schedule.
+// Every event must repeat weekly without an end, and start at a whole minute in
+// local time (either floating or with a TZID, which is ignored). The state is
+// read from the X-HIVE-STATUS and X-HIVE-BRIGHTNESS properties if present, or
+// otherwise from a summary like "On", "On (80%)" or "Off".
+//
+// Entries last until the next one starts, so events without an end, or ending
+// at midnight, last until the next event. If an event that turns the light on
+// ends (using DTEND or DURATION) before the next event of the same day starts,
+// an entry turning it off is added when it ends.
+//
+// Events that can't be represented as a weekly schedule, such as events that
+// overlap, start at the same time or continue past midnight, result in an error
+// wrapping ErrUnrepresentable.
+func ParseICalendar(data []byte) (Schedule, error) {
+	lines, err := unfoldICalendar(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var days [7][]icalEvent
+	var event []icalProperty
+	inEvent := false
+	for _, prop := range lines {
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT"):
+			inEvent = true
+			event = nil
+		case prop.name == "END" && strings.EqualFold(prop.value, "VEVENT"):
+			if !inEvent {
+				return nil, errors.New("END:VEVENT without BEGIN:VEVENT")
+			}
+			inEvent = false
+			parsed, weekdays, err := parseICalendarEvent(event)
+			if err != nil {
+				return nil, err
+			}
+			for _, day := range weekdays {
+				days[day] = append(days[day], parsed)
+			}
+		case inEvent:
+			event = append(event, prop)
+		}
+	}
+	if inEvent {
+		return nil, errors.New("BEGIN:VEVENT without END:VEVENT")
+	}
+
+	schedule := make(Schedule)
+	for day, events := range days {
+		entries, err := icalendarEntries(time.Weekday(day), events)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			schedule[time.Weekday(day)] = entries
+		}
+	}
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// unfoldICalendar splits the document into content lines, joining lines that
+// were folded.
+func unfoldICalendar(data []byte) ([]icalProperty, error) {
+	var raw []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if len(text) > 0 && (text[0] == ' ' || text[0] == '\t') && len(raw) > 0 {
+			raw[len(raw)-1] += text[1:]
+			continue
+		}
+		if text != "" {
+			raw = append(raw, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	props := make([]icalProperty, len(raw))
+	for i, text := range raw {
+		prop, err := parseICalendarLine(text)
+		if err != nil {
+			return nil, err
+		}
+		props[i] = prop
+	}
+	return props, nil
+}
+
+func parseICalendarLine(text string) (icalProperty, error) {
+	colon := -1
+	quoted := false
+	for i, r := range text {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return icalProperty{}, fmt.Errorf("invalid iCalendar line %q", text)
+	}
+
+	parts := strings.Split(text[:colon], ";")
+	prop := icalProperty{
+		name:   strings.ToUpper(parts[0]),
+		params: make(map[string]string),
+		value:  text[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		if eq := strings.IndexByte(param, '='); eq >= 0 {
+			prop.params[strings.ToUpper(param[:eq])] = strings.Trim(param[eq+1:], `"`)
+		}
+	}
+	return prop, nil
+}
+
+// icalEvent is an event of an iCalendar document, on one of the days it
+// repeats on.
+type icalEvent struct {
+	name  string
+	start time.Duration
+	value ScheduleValue
+
+	// end is when the event ends, relative to the start of its day, or zero if
+	// it lasts until the next event.
+	end time.Duration
+}
+
+// parseICalendarEvent returns the event with the given properties and the days
+// of the week it repeats on.
+func parseICalendarEvent(event []icalProperty) (icalEvent, []time.Weekday, error) {
+	props := make(map[string]icalProperty, len(event))
+	for _, prop := range event {
+		props[prop.name] = prop
+	}
+	name := props["UID"].value
+	if name == "" {
+		name = props["SUMMARY"].value
+	}
+	unrepresentable := func(reason string) error {
+		return fmt.Errorf("event %q %w: %s", name, ErrUnrepresentable, reason)
+	}
+
+	for _, unsupported := range []string{"RDATE", "EXDATE", "EXRULE", "RECURRENCE-ID"} {
+		if _, ok := props[unsupported]; ok {
+			return icalEvent{}, nil, unrepresentable(unsupported + " is not supported")
+		}
+	}
+
+	dtstart, ok := props["DTSTART"]
+	if !ok {
+		return icalEvent{}, nil, fmt.Errorf("event %q has no DTSTART", name)
+	}
+	if dtstart.params["VALUE"] == "DATE" || len(dtstart.value) == len("20060102") {
+		return icalEvent{}, nil, unrepresentable("all-day events have no start time")
+	}
+	if strings.HasSuffix(dtstart.value, "Z") {
+		return icalEvent{}, nil, unrepresentable("start time is in UTC, use local time instead")
+	}
+	start, err := time.Parse(icalDateTime, dtstart.value)
+	if err != nil {
+		return icalEvent{}, nil, fmt.Errorf("event %q has invalid DTSTART %q", name, dtstart.value)
+	}
+	if start.Second() != 0 {
+		return icalEvent{}, nil, unrepresentable("start time is not a whole minute")
+	}
+
+	days, err := icalendarDays(props["RRULE"], start.Weekday())
+	if err != nil {
+		return icalEvent{}, nil, unrepresentable(err.Error())
+	}
+	value, err := icalendarValue(props)
+	if err != nil {
+		return icalEvent{}, nil, fmt.Errorf("event %q: %v", name, err)
+	}
+
+	parsed := icalEvent{name: name, start: timeOfDay(start), value: value}
+	var length time.Duration
+	if dtend, ok := props["DTEND"]; ok {
+		if strings.HasSuffix(dtend.value, "Z") {
+			return icalEvent{}, nil, unrepresentable("end time is in UTC, use local time instead")
+		}
+		end, err := time.Parse(icalDateTime, dtend.value)
+		if err != nil {
+			return icalEvent{}, nil, fmt.Errorf("event %q has invalid DTEND %q", name, dtend.value)
+		}
+		length = end.Sub(start)
+	} else if duration, ok := props["DURATION"]; ok {
+		if length, err = parseICalendarDuration(duration.value); err != nil {
+			return icalEvent{}, nil, fmt.Errorf("event %q has invalid DURATION %q", name, duration.value)
+		}
+	} else {
+		return parsed, days, nil
+	}
+	if length <= 0 {
+		return icalEvent{}, nil, fmt.Errorf("event %q doesn't end after it starts", name)
+	}
+	// Events ending at midnight last until the next event, like the ones
+	// exported by MarshalICalendar.
+	if parsed.end = parsed.start + length; parsed.end == 24*time.Hour {
+		parsed.end = 0
+	}
+	return parsed, days, nil
+}
+
+// icalendarEntries returns the schedule entries for the events of a single day.
+func icalendarEntries(day time.Weekday, events []icalEvent) ([]ScheduleEntry, error) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].start < events[j].start
+	})
+
+	var entries []ScheduleEntry
+	for i, event := range events {
+		var next *icalEvent
+		if i+1 < len(events) {
+			next = &events[i+1]
+		}
+		if next != nil && next.start == event.start {
+			return nil, fmt.Errorf("events %q and %q %w: both start at %s on %s",
+				event.name, next.name, ErrUnrepresentable, formatTimeOfDay(event.start), day)
+		}
+
+		entries = append(entries, ScheduleEntry{Start: event.start, Value: event.value})
+		if event.end == 0 || (next != nil && next.start == event.end) {
+			continue
+		}
+		if next != nil && next.start < event.end {
+			return nil, fmt.Errorf("events %q and %q %w: they overlap on %s",
+				event.name, next.name, ErrUnrepresentable, day)
+		}
+		if event.end > 24*time.Hour {
+			return nil, fmt.Errorf("event %q %w: it continues past midnight on %s",
+				event.name, ErrUnrepresentable, day)
+		}
+		if event.value.On {
+			entries = append(entries, ScheduleEntry{Start: event.end, Value: ScheduleValue{}})
+		}
+	}
+	return entries, nil
+}
+
+// parseICalendarDuration parses a positive iCalendar duration such as "PT1H30M"
+// or "P1D".
+func parseICalendarDuration(value string) (time.Duration, error) {
+	match := icalDuration.FindStringSubmatch(strings.TrimPrefix(value, "+"))
+	if match == nil || strings.HasSuffix(value, "P") || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var duration time.Duration
+	for i, unit := range units {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return 0, err
+		}
+		duration += time.Duration(n) * unit
+	}
+	return duration, nil
+}
+
+// icalendarDays returns the days of the week the event repeats on, based on its
+// recurrence rule.
+func icalendarDays(rrule icalProperty, startDay time.Weekday) ([]time.Weekday, error) {
+	if rrule.value == "" {
+		return nil, errors.New("event doesn't repeat weekly")
+	}
+
+	var days []time.Weekday
+	for _, part := range strings.Split(rrule.value, ";") {
+		eq := strings.IndexByte(part, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+		key, value := strings.ToUpper(part[:eq]), strings.ToUpper(part[eq+1:])
+		switch key {
+		case "FREQ":
+			if value != "WEEKLY" {
+				return nil, fmt.Errorf("event repeats %s instead of weekly", strings.ToLower(value))
+			}
+		case "INTERVAL":
+			if value != "1" {
+				return nil, fmt.Errorf("event repeats every %s weeks", value)
+			}
+		case "WKST":
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day := -1
+				for i, name := range icalDays {
+					if code == name {
+						day = i
+					}
+				}
+				if day < 0 {
+					return nil, fmt.Errorf("unsupported day %q", code)
+				}
+				days = append(days, time.Weekday(day))
+			}
+		case "COUNT", "UNTIL":
+			return nil, errors.New("recurrence has an end")
+		default:
+			return nil, fmt.Errorf("%s is not supported", key)
+		}
+	}
+	if !strings.Contains(strings.ToUpper(rrule.value), "FREQ=") {
+		return nil, errors.New("RRULE has no FREQ")
+	}
+	if len(days) == 0 {
+		days = []time.Weekday{startDay}
+	}
+	return days, nil
+}
+
+// icalendarValue returns the state an event sets.
+func icalendarValue(props map[string]icalProperty) (ScheduleValue, error) {
+	var value ScheduleValue
+	if status, ok := props["X-HIVE-STATUS"]; ok {
+		switch strings.ToUpper(status.value) {
+		case statusON:
+			value.On = true
+		case statusOFF:
+		default:
+			return value, fmt.Errorf("invalid X-HIVE-STATUS %q", status.value)
+		}
+		if brightness, ok := props["X-HIVE-BRIGHTNESS"]; ok {
+			b, err := strconv.Atoi(brightness.value)
+			if err != nil {
+				return value, fmt.Errorf("invalid X-HIVE-BRIGHTNESS %q", brightness.value)
+			}
+			value.Brightness = b
+		}
+		return value, nil
+	}
+
+	summary := strings.TrimSpace(props["SUMMARY"].value)
+	var brightness string
+	if open := strings.IndexByte(summary, '('); open >= 0 && strings.HasSuffix(summary, "%)") {
+		brightness = summary[open+1 : len(summary)-2]
+		summary = strings.TrimSpace(summary[:open])
+	}
+	switch strings.ToUpper(summary) {
+	case statusON:
+		value.On = true
+		value.Brightness = 100
+		if brightness != "" {
+			b, err := strconv.Atoi(brightness)
+			if err != nil {
+				return value, fmt.Errorf("invalid brightness %q in summary", brightness)
+			}
+			value.Brightness = b
+		}
+	case statusOFF:
+	default:
+		return value, fmt.Errorf("can't tell state from summary %q, expected On, On (N%%) or Off", props["SUMMARY"].value)
+	}
+	return value, nil
+}
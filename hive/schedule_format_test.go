@@ -0,0 +1,159 @@
+package hive
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSchedule = Schedule{
+	time.Monday: {
+		{7*time.Hour + 30*time.Minute, ScheduleValue{true, 80}},
+		{23 * time.Hour, ScheduleValue{false, 0}},
+	},
+	time.Sunday: {
+		{9 * time.Hour, ScheduleValue{true, 100}},
+	},
+}
+
+func TestScheduleJSON(t *testing.T) {
+	data, err := json.Marshal(testSchedule)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+	want := `{"monday":[{"start":"07:30","on":true,"brightness":80},{"start":"23:00","on":false,"brightness":0}],` +
+		`"sunday":[{"start":"09:00","on":true,"brightness":100}]}`
+	if string(data) != want {
+		t.Errorf("json.Marshal returned %s, want %s", data, want)
+	}
+
+	var schedule Schedule
+	if err := json.Unmarshal(data, &schedule); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if !reflect.DeepEqual(schedule, testSchedule) {
+		t.Errorf("json.Unmarshal returned %v, want %v", schedule, testSchedule)
+	}
+
+	for _, invalid := range []string{
+		`{"monday":[{"start":"7:3"}]}`,
+		`{"monday":[{"start":"08:00"},{"start":"07:00"}]}`,
+		`{"funday":[]}`,
+	} {
+		if err := json.Unmarshal([]byte(invalid), &schedule); err == nil {
+			t.Errorf("json.Unmarshal returned no error for %s", invalid)
+		}
+	}
+}
+
+func TestScheduleICalendar(t *testing.T) {
+	data := testSchedule.MarshalICalendar()
+	if !strings.Contains(string(data), "DTSTART:20240101T073000\r\nDTEND:20240101T230000\r\nRRULE:FREQ=WEEKLY;BYDAY=MO\r\n") {
+		t.Errorf("MarshalICalendar returned no event for the first Monday entry:\n%s", data)
+	}
+
+	schedule, err := ParseICalendar(data)
+	if err != nil {
+		t.Fatalf("ParseICalendar returned error: %v", err)
+	}
+	if !reflect.DeepEqual(schedule, testSchedule) {
+		t.Errorf("ParseICalendar returned %v, want %v", schedule, testSchedule)
+	}
+}
+
+func TestParseICalendar(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:lights\r\n" +
+		"DTSTART;TZID=Europe/London:20240102T180000\r\n" +
+		"RRULE:FREQ=WEEKLY;BYDAY=TU,\r\n TH\r\nSUMMARY:On (60%)\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	want := Schedule{
+		time.Tuesday:  {{18 * time.Hour, ScheduleValue{true, 60}}},
+		time.Thursday: {{18 * time.Hour, ScheduleValue{true, 60}}},
+	}
+	schedule, err := ParseICalendar([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseICalendar returned error: %v", err)
+	}
+	if !reflect.DeepEqual(schedule, want) {
+		t.Errorf("ParseICalendar returned %v, want %v", schedule, want)
+	}
+
+	unrepresentable := []string{
+		"DTSTART:20240102T180000\r\nRRULE:FREQ=DAILY\r\nSUMMARY:On",
+		"DTSTART:20240102T180000\r\nRRULE:FREQ=WEEKLY;COUNT=3\r\nSUMMARY:On",
+		"DTSTART:20240102T180000\r\nRRULE:FREQ=WEEKLY;INTERVAL=2\r\nSUMMARY:On",
+		"DTSTART:20240102T180000Z\r\nRRULE:FREQ=WEEKLY\r\nSUMMARY:On",
+		"DTSTART;VALUE=DATE:20240102\r\nRRULE:FREQ=WEEKLY\r\nSUMMARY:On",
+		"DTSTART:20240102T180000\r\nSUMMARY:On",
+	}
+	for _, event := range unrepresentable {
+		data := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\n" + event + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+		if _, err := ParseICalendar([]byte(data)); !errors.Is(err, ErrUnrepresentable) {
+			t.Errorf("ParseICalendar returned %v for event %q, want %v", err, event, ErrUnrepresentable)
+		}
+	}
+}
+
+func TestParseICalendarEnd(t *testing.T) {
+	calendar := func(events ...string) []byte {
+		data := "BEGIN:VCALENDAR\r\n"
+		for _, event := range events {
+			data += "BEGIN:VEVENT\r\n" + event + "\r\nRRULE:FREQ=WEEKLY\r\nEND:VEVENT\r\n"
+		}
+		return []byte(data + "END:VCALENDAR\r\n")
+	}
+	evening := Schedule{time.Tuesday: {
+		{18 * time.Hour, ScheduleValue{true, 100}},
+		{20 * time.Hour, ScheduleValue{}},
+	}}
+
+	tests := []struct {
+		name string
+		data []byte
+		want Schedule
+	}{
+		{"dtend", calendar("DTSTART:20240102T180000\r\nDTEND:20240102T200000\r\nSUMMARY:On"), evening},
+		{"duration", calendar("DTSTART:20240102T180000\r\nDURATION:PT2H\r\nSUMMARY:On"), evening},
+		{"ends when next starts", calendar(
+			"DTSTART:20240102T180000\r\nDTEND:20240102T200000\r\nSUMMARY:On",
+			"DTSTART:20240102T200000\r\nSUMMARY:On (30%)",
+		), Schedule{time.Tuesday: {
+			{18 * time.Hour, ScheduleValue{true, 100}},
+			{20 * time.Hour, ScheduleValue{true, 30}},
+		}}},
+		{"ends at midnight", calendar("DTSTART:20240102T180000\r\nDTEND:20240103T000000\r\nSUMMARY:On"),
+			Schedule{time.Tuesday: {{18 * time.Hour, ScheduleValue{true, 100}}}}},
+	}
+	for _, test := range tests {
+		schedule, err := ParseICalendar(test.data)
+		if err != nil {
+			t.Errorf("%s: ParseICalendar returned error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(schedule, test.want) {
+			t.Errorf("%s: ParseICalendar returned %v, want %v", test.name, schedule, test.want)
+		}
+	}
+
+	unrepresentable := map[string][]byte{
+		"same start": calendar(
+			"UID:first\r\nDTSTART:20240102T180000\r\nSUMMARY:On",
+			"UID:second\r\nDTSTART:20240102T180000\r\nSUMMARY:Off",
+		),
+		"overlap": calendar(
+			"UID:first\r\nDTSTART:20240102T180000\r\nDURATION:PT3H\r\nSUMMARY:On",
+			"UID:second\r\nDTSTART:20240102T200000\r\nSUMMARY:Off",
+		),
+		"past midnight": calendar("UID:first\r\nDTSTART:20240102T230000\r\nDURATION:PT2H\r\nSUMMARY:On"),
+	}
+	for name, data := range unrepresentable {
+		_, err := ParseICalendar(data)
+		if !errors.Is(err, ErrUnrepresentable) {
+			t.Errorf("%s: ParseICalendar returned %v, want %v", name, err, ErrUnrepresentable)
+		} else if !strings.Contains(err.Error(), `"first"`) {
+			t.Errorf("%s: error %q doesn't name the event", name, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package hive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	device := parseTestDevice(t, `{
+		"id": "12345678-abcd",
+		"type": "warmwhitelight",
+		"state": {"schedule": {
+			"monday": [
+				{"start": 420, "value": {"status": "ON", "brightness": 80}},
+				{"start": 1380, "value": {"status": "OFF"}}
+			],
+			"tuesday": []
+		}}
+	}`)
+
+	want := Schedule{
+		time.Monday: {
+			{7 * time.Hour, ScheduleValue{true, 80}},
+			{23 * time.Hour, ScheduleValue{false, 0}},
+		},
+	}
+	schedule := device.Schedule()
+	if !reflect.DeepEqual(schedule, want) {
+		t.Errorf("Schedule returned %v, want %v", schedule, want)
+	}
+
+	change := NewChange().Schedule(schedule)
+	if got := scheduleFromJSON(change.state.Schedule); !reflect.DeepEqual(got, want) {
+		t.Errorf("Change has schedule %v, want %v", got, want)
+	}
+	if change.state.Schedule.Sunday == nil {
+		t.Error("Change has no entries set for Sunday, want an empty list")
+	}
+}
+
+func TestScheduleHeating(t *testing.T) {
+	device := parseTestDevice(t, `{
+		"id": "12345678-abcd",
+		"type": "heating",
+		"state": {"schedule": {"monday": [{"start": 420, "value": {"target": 20}}]}}
+	}`)
+	if schedule := device.Schedule(); schedule != nil {
+		t.Errorf("Schedule returned %v for heating, want nil", schedule)
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	invalid := []Schedule{
+		{time.Monday: {{8 * time.Hour, ScheduleValue{}}, {7 * time.Hour, ScheduleValue{}}}},
+		{time.Monday: {{24 * time.Hour, ScheduleValue{}}}},
+		{time.Monday: {{time.Hour + time.Second, ScheduleValue{}}}},
+		{time.Monday: {{time.Hour, ScheduleValue{true, 101}}}},
+		{time.Weekday(7): {{time.Hour, ScheduleValue{}}}},
+	}
+	for _, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Validate returned no error for invalid schedule %v", s)
+		}
+	}
+}
+
+func TestScheduleAt(t *testing.T) {
+	on := ScheduleEntry{7 * time.Hour, ScheduleValue{true, 100}}
+	off := ScheduleEntry{23 * time.Hour, ScheduleValue{false, 0}}
+	schedule := Schedule{time.Monday: {on, off}, time.Wednesday: {on}}
+
+	location, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	// 2026-03-30 is a Monday, the day after the clocks go forward.
+	monday := time.Date(2026, 3, 30, 0, 0, 0, 0, location)
+	tests := []struct {
+		time     time.Time
+		want     ScheduleEntry
+		wantNext time.Time
+	}{
+		{monday.Add(6 * time.Hour), on, monday.Add(7 * time.Hour)},
+		{monday.Add(7 * time.Hour), on, monday.Add(23 * time.Hour)},
+		{monday.Add(23*time.Hour + time.Minute), off, monday.AddDate(0, 0, 2).Add(7 * time.Hour)},
+		{monday.AddDate(0, 0, 1).Add(12 * time.Hour), off, monday.AddDate(0, 0, 2).Add(7 * time.Hour)},
+		{monday.AddDate(0, 0, 6), on, time.Date(2026, 4, 6, 7, 0, 0, 0, location)},
+	}
+	for _, test := range tests {
+		if got, ok := schedule.At(test.time); !ok || got != test.want {
+			t.Errorf("At(%v) returned %v, %v, want %v, true", test.time, got, ok, test.want)
+		}
+		if next, _, ok := schedule.NextChange(test.time); !ok || !next.Equal(test.wantNext) {
+			t.Errorf("NextChange(%v) returned %v, %v, want %v, true", test.time, next, ok, test.wantNext)
+		}
+	}
+
+	if _, ok := (Schedule{}).At(monday); ok {
+		t.Error("At returned an entry for an empty schedule")
+	}
+	if _, _, ok := (Schedule{}).NextChange(monday); ok {
+		t.Error("NextChange returned an entry for an empty schedule")
+	}
+}
@@ -0,0 +1,120 @@
+package hive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Session contains everything needed to resume a login without sending the
+// credentials again.
+type Session struct {
+	// Username is the username the session belongs to.
+	Username string `json:"username"`
+
+	// Token is the authentication token obtained by logging in.
+	Token string `json:"token"`
+
+	// EndpointURL is the URL to the API endpoint obtained by logging in.
+	EndpointURL string `json:"endpointURL"`
+
+	// Timezone is the name of the time zone of the user's home.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// TokenStore persists sessions, so a client can reuse a token instead of
+// logging in from scratch.
+type TokenStore interface {
+	// Load returns the saved session, or nil if there is none.
+	Load() (*Session, error)
+
+	// Save replaces the saved session with the given one.
+	Save(session *Session) error
+}
+
+// FileTokenStore is a TokenStore that saves the session as JSON to a file
+// readable only by its owner.
+type FileTokenStore struct {
+	// Path is the path to the file the session is saved in.
+	Path string
+}
+
+// NewFileTokenStore returns a TokenStore saving the session to the given path.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{Path: path}
+}
+
+// Load reads the session from the file. It returns nil if the file doesn't
+// exist.
+func (s *FileTokenStore) Load() (*Session, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Save atomically replaces the file with the given session. The file is created
+// with 0600 permissions.
+func (s *FileTokenStore) Save(session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.Path)
+}
+
+// MemoryTokenStore is a TokenStore that keeps the session in memory. It's
+// useful for sharing a session between multiple clients in the same process.
+type MemoryTokenStore struct {
+	mu      sync.Mutex
+	session *Session
+}
+
+// Load returns a copy of the saved session, or nil if there is none.
+func (s *MemoryTokenStore) Load() (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session == nil {
+		return nil, nil
+	}
+	session := *s.session
+	return &session, nil
+}
+
+// Save stores a copy of the given session.
+func (s *MemoryTokenStore) Save(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := *session
+	s.session = &saved
+	return nil
+}
@@ -0,0 +1,42 @@
+package hive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileTokenStore(filepath.Join(dir, "session.json"))
+
+	session, err := store.Load()
+	if session != nil || err != nil {
+		t.Errorf("store.Load returned %v, %v for missing file, want nil, nil", session, err)
+	}
+
+	want := Session{"user", "1234567890", "https://example.com/", "Europe/London"}
+	if err := store.Save(&want); err != nil {
+		t.Fatalf("store.Save returned error: %v", err)
+	}
+	info, err := os.Stat(store.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("session file has permissions %v, want 0600", info.Mode().Perm())
+	}
+
+	session, err = store.Load()
+	if err != nil {
+		t.Errorf("store.Load returned error: %v", err)
+	}
+	if session == nil || *session != want {
+		t.Errorf("store.Load returned %+v, want %+v", session, want)
+	}
+}
@@ -0,0 +1,114 @@
+package hive
+
+import "sort"
+
+// WalkFunc is called by Walk for every device, with depth being 0 for devices
+// without a parent, 1 for their children and so on. If it returns an error,
+// the walk stops and Walk returns that error.
+type WalkFunc func(device *Device, depth int) error
+
+// IsHub returns true if this device is a hub other devices connect through.
+func (d *Device) IsHub() bool {
+	return d.Type() == typeHub
+}
+
+// Parent returns the device this device is connected through, usually a hub,
+// or nil if it has no parent or the parent isn't known to the client.
+func (d *Device) Parent() *Device {
+	parent := d.snapshot().Parent
+	if parent == "" {
+		return nil
+	}
+	return d.client.Device(parent)
+}
+
+// Children returns the devices connected through this device, ordered the same
+// way as in the Hive app.
+func (d *Device) Children() []*Device {
+	id := d.ID()
+	var children []*Device
+	for _, device := range d.client.Devices() {
+		if device.snapshot().Parent == id {
+			children = append(children, device)
+		}
+	}
+	sortDevices(children)
+	return children
+}
+
+// Hubs returns all the hubs, ordered the same way as in the Hive app.
+func (c *Client) Hubs() []*Device {
+	var hubs []*Device
+	for _, device := range c.Devices() {
+		if device.IsHub() {
+			hubs = append(hubs, device)
+		}
+	}
+	sortDevices(hubs)
+	return hubs
+}
+
+// Walk calls fn for every device, visiting each device before its children.
+// Devices without a parent known to the client, such as hubs, are visited
+// first, with their children following in depth-first order.
+func (c *Client) Walk(fn WalkFunc) error {
+	devices := c.Devices()
+	children := make(map[string][]*Device, len(devices))
+	var roots []*Device
+	for _, device := range devices {
+		parent := device.snapshot().Parent
+		if parent == "" || c.Device(parent) == nil {
+			roots = append(roots, device)
+		} else {
+			children[parent] = append(children[parent], device)
+		}
+	}
+
+	visited := make(map[string]bool, len(devices))
+	var walk func(devices []*Device, depth int) error
+	walk = func(devices []*Device, depth int) error {
+		sortDevices(devices)
+		for _, device := range devices {
+			// Guard against malformed data where devices are parents of each other.
+			if visited[device.ID()] {
+				continue
+			}
+			visited[device.ID()] = true
+			if err := fn(device, depth); err != nil {
+				return err
+			}
+			if err := walk(children[device.ID()], depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(roots, 0)
+}
+
+// sortDevices sorts devices by their sort order in the Hive app, and then by
+// ID for devices with the same sort order.
+func sortDevices(devices []*Device) {
+	sort.Slice(devices, func(i, j int) bool {
+		return entityLess(devices[i].snapshot(), devices[j].snapshot())
+	})
+}
+
+// entityLess returns true if the device a comes before b in the Hive app.
+func entityLess(a, b *jsonEntity) bool {
+	if a.SortOrder != b.SortOrder {
+		return a.SortOrder < b.SortOrder
+	}
+	return a.ID < b.ID
+}
+
+// hubsOf returns the hubs in the given list of devices.
+func hubsOf(devices []jsonEntity) []jsonEntity {
+	var hubs []jsonEntity
+	for _, device := range devices {
+		if device.Type == typeHub {
+			hubs = append(hubs, device)
+		}
+	}
+	return hubs
+}
@@ -0,0 +1,100 @@
+package hive
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	mock := &mockEndpoint{}
+	client := &Client{client: mock}
+	mock.result = `{
+		"token": "1234567890",
+		"platform": {"endpoint": "https://example.com/"},
+		"products": [
+			{"id": "light-2", "type": "warmwhitelight", "parent": "hub", "sortOrder": 2},
+			{"id": "light-1", "type": "colourtuneablelight", "parent": "hub", "sortOrder": 1},
+			{"id": "orphan", "type": "motionsensor", "parent": "unknown"}
+		],
+		"devices": [
+			{"id": "hub", "type": "hub"},
+			{"id": "light-1", "type": "colourtuneablelight", "parent": "hub"}
+		]
+	}`
+	if err := client.Login(&Credentials{"user", "secret", "http://example.com/"}); err != nil {
+		t.Fatalf("client.Login returned error: %v", err)
+	}
+
+	hubs := client.Hubs()
+	if len(hubs) != 1 || hubs[0].ID() != "hub" {
+		t.Fatalf("client.Hubs returned %v, want only the hub", hubs)
+	}
+	if parent := client.Device("light-1").Parent(); parent != hubs[0] {
+		t.Errorf("Parent of light-1 is %v, want the hub", parent)
+	}
+	if children := hubs[0].Children(); len(children) != 2 || children[0].ID() != "light-1" {
+		t.Errorf("Children of hub are %v, want light-1 and light-2", children)
+	}
+
+	var visited []string
+	client.Walk(func(device *Device, depth int) error {
+		visited = append(visited, fmt.Sprintf("%d:%s", depth, device.ID()))
+		return nil
+	})
+	want := []string{"0:hub", "1:light-1", "1:light-2", "0:orphan"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("Walk visited %v, want %v", visited, want)
+	}
+}
+
+func TestResumedSessionLoadsHubs(t *testing.T) {
+	store := &MemoryTokenStore{}
+	store.Save(&Session{"user", "saved", "https://example.com/", ""})
+	hubRequests := 0
+	hubsFail := false
+	mock := &mockEndpoint{handle: func(url string, token string) (string, error) {
+		switch url {
+		case "https://example.com/products?after=":
+			return `[{"id": "light", "type": "warmwhitelight", "parent": "hub"}]`, nil
+		case "https://example.com/devices":
+			hubRequests++
+			if hubsFail {
+				return "", errors.New("connection reset")
+			}
+			return `[{"id": "hub", "type": "hub", "props": {"online": true}}, {"id": "light", "type": "warmwhitelight"}]`, nil
+		}
+		return "", fmt.Errorf("unexpected request to %s", url)
+	}}
+	client := &Client{client: mock, TokenStore: store}
+
+	if err := client.Login(&Credentials{"user", "secret", "https://example.com/login"}); err != nil {
+		t.Fatalf("client.Login returned error: %v", err)
+	}
+	hubs := client.Hubs()
+	if len(hubs) != 1 || !hubs[0].IsOnline() {
+		t.Fatalf("client.Hubs returned %v after resuming session, want the online hub", hubs)
+	}
+	if parent := client.Device("light").Parent(); parent != hubs[0] {
+		t.Errorf("light has parent %v, want %v", parent, hubs[0])
+	}
+	if len(client.Devices()) != 2 {
+		t.Errorf("client has %d devices, want the light and the hub", len(client.Devices()))
+	}
+
+	if err := client.RefreshDevices(); err != nil {
+		t.Fatalf("client.RefreshDevices returned error: %v", err)
+	}
+	if hubRequests != 1 || len(client.Hubs()) != 1 {
+		t.Errorf("refreshing made %d requests for hubs and left %d hubs, want 1 and 1", hubRequests, len(client.Hubs()))
+	}
+
+	hubsFail = true
+	if err := client.Login(&Credentials{"user", "secret", "https://example.com/login"}); err != nil {
+		t.Fatalf("client.Login returned error when only loading hubs failed: %v", err)
+	}
+	if len(client.Hubs()) != 1 {
+		t.Errorf("client has %d hubs after loading them failed, want the one already loaded", len(client.Hubs()))
+	}
+}
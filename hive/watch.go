@@ -0,0 +1,293 @@
+package hive
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Returned in an EventError when watching with an interval that isn't positive.
+var ErrInvalidInterval = errors.New("watch interval must be positive")
+
+// watchBuffer is the number of events that can be waiting to be received before
+// watching blocks.
+const watchBuffer = 16
+
+// EventType describes what changed about a device.
+type EventType int
+
+const (
+	// EventError means refreshing the devices failed. The error is in Event.Err
+	// and watching continues with the next refresh, unless the error is
+	// ErrInvalidInterval.
+	EventError EventType = iota
+
+	// EventDeviceAdded means a new device was found.
+	EventDeviceAdded
+
+	// EventDeviceRemoved means a device is no longer present.
+	EventDeviceRemoved
+
+	// EventOnline means a device came back online.
+	EventOnline
+
+	// EventOffline means a device went offline.
+	EventOffline
+
+	// EventTurnedOn means a light, plug or hot water device was turned on.
+	EventTurnedOn
+
+	// EventTurnedOff means a light, plug or hot water device was turned off.
+	EventTurnedOff
+
+	// EventBrightnessChanged means the brightness of a light changed.
+	EventBrightnessChanged
+
+	// EventColorChanged means the color, color temperature or color mode of a
+	// light changed.
+	EventColorChanged
+
+	// EventMotionStarted means a motion sensor started detecting motion. Motion
+	// that started and ended between two refreshes is reported as well, followed
+	// by EventMotionEnded.
+	EventMotionStarted
+
+	// EventMotionEnded means a motion sensor stopped detecting motion.
+	EventMotionEnded
+
+	// EventOpened means the window or door a contact sensor is attached to was
+	// opened.
+	EventOpened
+
+	// EventClosed means the window or door a contact sensor is attached to was
+	// closed.
+	EventClosed
+
+	// EventNameChanged means a device was renamed.
+	EventNameChanged
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventError:
+		return "error"
+	case EventDeviceAdded:
+		return "added"
+	case EventDeviceRemoved:
+		return "removed"
+	case EventOnline:
+		return "online"
+	case EventOffline:
+		return "offline"
+	case EventTurnedOn:
+		return "turned on"
+	case EventTurnedOff:
+		return "turned off"
+	case EventBrightnessChanged:
+		return "brightness changed"
+	case EventColorChanged:
+		return "color changed"
+	case EventMotionStarted:
+		return "motion started"
+	case EventMotionEnded:
+		return "motion ended"
+	case EventOpened:
+		return "opened"
+	case EventClosed:
+		return "closed"
+	case EventNameChanged:
+		return "name changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change to a device noticed while watching a client.
+type Event struct {
+	// Type is what changed.
+	Type EventType
+
+	// Device is the device that changed. For removed devices, it has the last
+	// known state of the device.
+	Device *Device
+
+	// Previous is a copy of the device with the state it had before the change.
+	// It's nil for added devices and errors, and must only be used to read the
+	// previous state.
+	Previous *Device
+
+	// Time is when the change was noticed.
+	Time time.Time
+
+	// Err is the error that occurred while refreshing, for EventError.
+	Err error
+}
+
+// Watch refreshes the devices at the given interval until the context is done
+// and sends an event to the returned channel for every change it notices. The
+// first refresh is done right away, and changes are relative to the devices the
+// client already had, so devices are reported as added if the client hasn't
+// loaded them yet.
+//
+// Events are sent as they're found, and no refresh is done while the receiver
+// isn't keeping up, so refreshes are skipped instead of events being dropped.
+// The channel is closed once the context is done.
+//
+// The interval must be positive. Otherwise, nothing is refreshed and the channel
+// only receives an EventError with ErrInvalidInterval before being closed.
+func (c *Client) Watch(ctx context.Context, interval time.Duration) <-chan Event {
+	events := make(chan Event, watchBuffer)
+	if interval <= 0 {
+		events <- Event{Type: EventError, Time: time.Now(), Err: ErrInvalidInterval}
+		close(events)
+		return events
+	}
+	go c.watch(ctx, interval, events)
+	return events
+}
+
+func (c *Client) watch(ctx context.Context, interval time.Duration, events chan<- Event) {
+	defer close(events)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := c.snapshots()
+	for {
+		var changes []Event
+		if err := c.RefreshDevicesContext(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			changes = []Event{{Type: EventError, Time: time.Now(), Err: err}}
+		} else {
+			current := c.snapshots()
+			changes = c.diffDevices(previous, current, time.Now())
+			previous = current
+		}
+
+		for _, event := range changes {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// snapshots returns the current state of every device, by ID.
+func (c *Client) snapshots() map[string]*jsonEntity {
+	devices := c.Devices()
+	result := make(map[string]*jsonEntity, len(devices))
+	for _, device := range devices {
+		entity := device.snapshot()
+		result[entity.ID] = entity
+	}
+	return result
+}
+
+// diffDevices returns the events that turn the previous devices into the
+// current ones, ordered the same way as in the Hive app.
+func (c *Client) diffDevices(previous, current map[string]*jsonEntity, now time.Time) []Event {
+	var events []Event
+	for _, entity := range sortedEntities(current) {
+		device := c.Device(entity.ID)
+		if device == nil {
+			device = c.frozen(entity)
+		}
+		old, ok := previous[entity.ID]
+		if !ok {
+			events = append(events, Event{Type: EventDeviceAdded, Device: device, Time: now})
+			continue
+		}
+		for _, t := range diffDevice(c.frozen(old), c.frozen(entity)) {
+			events = append(events, Event{Type: t, Device: device, Previous: c.frozen(old), Time: now})
+		}
+	}
+	for _, entity := range sortedEntities(previous) {
+		if _, ok := current[entity.ID]; !ok {
+			device := c.frozen(entity)
+			events = append(events, Event{Type: EventDeviceRemoved, Device: device, Previous: device, Time: now})
+		}
+	}
+	return events
+}
+
+// frozen returns a device that always has the given state.
+func (c *Client) frozen(entity *jsonEntity) *Device {
+	return &Device{entity: entity, client: c}
+}
+
+// sortedEntities returns the given entities ordered the same way as in the Hive
+// app.
+func sortedEntities(entities map[string]*jsonEntity) []*jsonEntity {
+	result := make([]*jsonEntity, 0, len(entities))
+	for _, entity := range entities {
+		result = append(result, entity)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return entityLess(result[i], result[j])
+	})
+	return result
+}
+
+// diffDevice returns the types of the changes between two states of the same
+// device.
+func diffDevice(old, new *Device) []EventType {
+	var changes []EventType
+	if old.Name() != new.Name() {
+		changes = append(changes, EventNameChanged)
+	}
+	if old.IsOnline() != new.IsOnline() {
+		changes = append(changes, boolEvent(new.IsOnline(), EventOnline, EventOffline))
+	}
+	if old.IsOn() != new.IsOn() {
+		changes = append(changes, boolEvent(new.IsOn(), EventTurnedOn, EventTurnedOff))
+	}
+	if new.IsLight() {
+		if old.Brightness() != new.Brightness() {
+			changes = append(changes, EventBrightnessChanged)
+		}
+		if old.Color() != new.Color() || old.ColorTemperature() != new.ColorTemperature() ||
+			colourMode(old.snapshot()) != colourMode(new.snapshot()) {
+			changes = append(changes, EventColorChanged)
+		}
+	}
+	if old.HasMotion() != new.HasMotion() {
+		changes = append(changes, boolEvent(new.HasMotion(), EventMotionStarted, EventMotionEnded))
+	} else if new.LastMotionStart().After(old.LastMotionStart()) && !old.LastMotionStart().IsZero() {
+		// Motion started again between the two refreshes. If there's no motion
+		// now, it also ended in between.
+		if new.HasMotion() {
+			changes = append(changes, EventMotionEnded, EventMotionStarted)
+		} else {
+			changes = append(changes, EventMotionStarted, EventMotionEnded)
+		}
+	}
+	if old.IsOpen() != new.IsOpen() {
+		changes = append(changes, boolEvent(new.IsOpen(), EventOpened, EventClosed))
+	}
+	return changes
+}
+
+func boolEvent(value bool, ifTrue, ifFalse EventType) EventType {
+	if value {
+		return ifTrue
+	}
+	return ifFalse
+}
+
+func colourMode(e *jsonEntity) string {
+	if e.State.ColourMode == nil {
+		return ""
+	}
+	return *e.State.ColourMode
+}
@@ -0,0 +1,112 @@
+package hive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDiffDevices(t *testing.T) {
+	client := &Client{client: &mockEndpoint{}}
+	previous := map[string]*jsonEntity{
+		"light":   parseTestDevice(t, `{"id": "light", "type": "colourtuneablelight", "state": {"status": "OFF", "brightness": 50, "colourMode": "WHITE"}}`).snapshot(),
+		"motion":  parseTestDevice(t, `{"id": "motion", "type": "motionsensor", "props": {"online": true, "motion": {"status": false}}}`).snapshot(),
+		"contact": parseTestDevice(t, `{"id": "contact", "type": "contactsensor", "props": {"status": "OPEN"}}`).snapshot(),
+		"old":     parseTestDevice(t, `{"id": "old", "type": "warmwhitelight"}`).snapshot(),
+	}
+	current := map[string]*jsonEntity{
+		"light":   parseTestDevice(t, `{"id": "light", "type": "colourtuneablelight", "state": {"status": "ON", "brightness": 80, "colourMode": "COLOUR"}}`).snapshot(),
+		"motion":  parseTestDevice(t, `{"id": "motion", "type": "motionsensor", "state": {"name": "Hall"}, "props": {"online": false, "motion": {"status": true}}}`).snapshot(),
+		"contact": parseTestDevice(t, `{"id": "contact", "type": "contactsensor", "props": {"status": "CLOSED"}}`).snapshot(),
+		"new":     parseTestDevice(t, `{"id": "new", "type": "warmwhitelight"}`).snapshot(),
+	}
+
+	got := make(map[string][]EventType)
+	for _, event := range client.diffDevices(previous, current, time.Now()) {
+		got[event.Device.ID()] = append(got[event.Device.ID()], event.Type)
+	}
+	want := map[string][]EventType{
+		"light":   {EventTurnedOn, EventBrightnessChanged, EventColorChanged},
+		"motion":  {EventNameChanged, EventOffline, EventMotionStarted},
+		"contact": {EventClosed},
+		"new":     {EventDeviceAdded},
+		"old":     {EventDeviceRemoved},
+	}
+	for id, types := range want {
+		if len(got[id]) != len(types) {
+			t.Errorf("events for %s are %v, want %v", id, got[id], types)
+			continue
+		}
+		for i := range types {
+			if got[id][i] != types[i] {
+				t.Errorf("events for %s are %v, want %v", id, got[id], types)
+				break
+			}
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	results := make(chan string, 2)
+	results <- `[{"id": "light", "type": "warmwhitelight", "state": {"status": "OFF"}}]`
+	results <- `[{"id": "light", "type": "warmwhitelight", "state": {"status": "ON"}}]`
+	mock := &mockEndpoint{handle: func(url string, token string) (string, error) {
+		select {
+		case result := <-results:
+			return result, nil
+		default:
+			return "", errors.New("no more results")
+		}
+	}}
+	client := &Client{client: mock}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := client.Watch(ctx, time.Millisecond)
+
+	for _, want := range []EventType{EventDeviceAdded, EventTurnedOn, EventError} {
+		event := <-events
+		if event.Type != want {
+			t.Fatalf("got %v event, want %v", event.Type, want)
+		}
+	}
+
+	cancel()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events channel not closed after context was canceled")
+		}
+	}
+}
+
+func TestWatchInvalidInterval(t *testing.T) {
+	client := &Client{client: &mockEndpoint{}}
+	events := client.Watch(context.Background(), 0)
+	if event := <-events; event.Type != EventError || !errors.Is(event.Err, ErrInvalidInterval) {
+		t.Errorf("got event %+v, want %v", event, ErrInvalidInterval)
+	}
+	if _, ok := <-events; ok {
+		t.Error("events channel not closed after invalid interval")
+	}
+}
+
+func TestDiffDeviceMissedMotion(t *testing.T) {
+	base := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	old := motionSensor(t, false, base, base.Add(time.Minute))
+	burst := motionSensor(t, false, base.Add(5*time.Minute), base.Add(6*time.Minute))
+	ongoing := motionSensor(t, true, base.Add(5*time.Minute), base.Add(time.Minute))
+
+	if changes := diffDevice(old, burst); len(changes) != 2 || changes[0] != EventMotionStarted || changes[1] != EventMotionEnded {
+		t.Errorf("changes for missed motion are %v, want started and ended", changes)
+	}
+	if changes := diffDevice(old, ongoing); len(changes) != 1 || changes[0] != EventMotionStarted {
+		t.Errorf("changes for new motion are %v, want started", changes)
+	}
+}